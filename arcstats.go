@@ -0,0 +1,152 @@
+//
+// Typed access to the ZFS ARC statistics in zfs:0:arcstats, plus
+// arcstat-style rates computed from two samples of them.
+
+package kstat
+
+import "time"
+
+// ArcStats is the ZFS ARC (Adaptive Replacement Cache) statistics
+// from the named kstat zfs:0:arcstats. Sizes are in bytes; everything
+// that is not a size is a counter that only goes up. Statistics that
+// a particular OS version doesn't have are left zero.
+type ArcStats struct {
+	// Snaptime is the Snaptime of the KStat when these statistics
+	// were obtained.
+	Snaptime int64 `kstat:"snaptime"`
+
+	// The current size of the ARC, its target size (c), the
+	// target size of the MRU part (p), and the limits on c.
+	Size uint64 `kstat:"size,gauge"`
+	C    uint64 `kstat:"c,gauge"`
+	P    uint64 `kstat:"p,gauge"`
+	CMin uint64 `kstat:"c_min,gauge"`
+	CMax uint64 `kstat:"c_max,gauge"`
+
+	// What the ARC's size is made up of.
+	DataSize     uint64 `kstat:"data_size,gauge"`
+	MetadataSize uint64 `kstat:"metadata_size,gauge"`
+	HdrSize      uint64 `kstat:"hdr_size,gauge"`
+	OtherSize    uint64 `kstat:"other_size,gauge"`
+	AnonSize     uint64 `kstat:"anon_size,gauge"`
+	MRUSize      uint64 `kstat:"mru_size,gauge"`
+	MRUGhostSize uint64 `kstat:"mru_ghost_size,gauge"`
+	MFUSize      uint64 `kstat:"mfu_size,gauge"`
+	MFUGhostSize uint64 `kstat:"mfu_ghost_size,gauge"`
+
+	MetaUsed  uint64 `kstat:"arc_meta_used,gauge"`
+	MetaLimit uint64 `kstat:"arc_meta_limit,gauge"`
+	MetaMax   uint64 `kstat:"arc_meta_max,gauge"`
+
+	// Overall hits and misses, then broken down by demand versus
+	// prefetch reads of data versus metadata.
+	Hits                   uint64 `kstat:"hits"`
+	Misses                 uint64 `kstat:"misses"`
+	DemandDataHits         uint64 `kstat:"demand_data_hits"`
+	DemandDataMisses       uint64 `kstat:"demand_data_misses"`
+	DemandMetadataHits     uint64 `kstat:"demand_metadata_hits"`
+	DemandMetadataMisses   uint64 `kstat:"demand_metadata_misses"`
+	PrefetchDataHits       uint64 `kstat:"prefetch_data_hits"`
+	PrefetchDataMisses     uint64 `kstat:"prefetch_data_misses"`
+	PrefetchMetadataHits   uint64 `kstat:"prefetch_metadata_hits"`
+	PrefetchMetadataMisses uint64 `kstat:"prefetch_metadata_misses"`
+
+	// Which ARC lists hits came from.
+	MRUHits      uint64 `kstat:"mru_hits"`
+	MRUGhostHits uint64 `kstat:"mru_ghost_hits"`
+	MFUHits      uint64 `kstat:"mfu_hits"`
+	MFUGhostHits uint64 `kstat:"mfu_ghost_hits"`
+
+	// Evictions and related things.
+	Deleted             uint64 `kstat:"deleted"`
+	MutexMiss           uint64 `kstat:"mutex_miss"`
+	EvictSkip           uint64 `kstat:"evict_skip"`
+	EvictL2Cached       uint64 `kstat:"evict_l2_cached"`
+	EvictL2Eligible     uint64 `kstat:"evict_l2_eligible"`
+	EvictL2Ineligible   uint64 `kstat:"evict_l2_ineligible"`
+	MemoryThrottleCount uint64 `kstat:"memory_throttle_count"`
+
+	// The L2ARC, if any.
+	L2Hits        uint64 `kstat:"l2_hits"`
+	L2Misses      uint64 `kstat:"l2_misses"`
+	L2Feeds       uint64 `kstat:"l2_feeds"`
+	L2ReadBytes   uint64 `kstat:"l2_read_bytes"`
+	L2WriteBytes  uint64 `kstat:"l2_write_bytes"`
+	L2WritesSent  uint64 `kstat:"l2_writes_sent"`
+	L2WritesDone  uint64 `kstat:"l2_writes_done"`
+	L2WritesError uint64 `kstat:"l2_writes_error"`
+	L2Size        uint64 `kstat:"l2_size,gauge"`
+	L2Asize       uint64 `kstat:"l2_asize,gauge"`
+	L2HdrSize     uint64 `kstat:"l2_hdr_size,gauge"`
+}
+
+// ArcRates is what arcstat reports about the ARC over an interval:
+// per-second rates of reads (ARC accesses), and hit percentages for
+// various sorts of reads.
+type ArcRates struct {
+	Interval time.Duration
+
+	// ARC accesses per second and the hit and miss percentages.
+	Reads   float64
+	Hits    float64
+	Misses  float64
+	HitPct  float64
+	MissPct float64
+
+	// Demand (non-prefetch) reads of data and metadata.
+	DemandReads  float64
+	DemandHitPct float64
+
+	// Prefetch reads of data and metadata.
+	PrefetchReads  float64
+	PrefetchHitPct float64
+
+	// Metadata reads, both demand and prefetch.
+	MetadataReads  float64
+	MetadataHitPct float64
+
+	// L2ARC reads per second (ie ARC misses that went to the
+	// L2ARC), hit percentage, and bytes read per second.
+	L2Reads     float64
+	L2HitPct    float64
+	L2ReadBytes float64
+
+	// The ARC size and target size at the end of the interval.
+	Size uint64
+	C    uint64
+}
+
+// RatesSince computes arcstat-style rates for the interval between
+// prev and a.
+func (a *ArcStats) RatesSince(prev *ArcStats) *ArcRates {
+	d := snapInterval(prev.Snaptime, a.Snaptime)
+	r := ArcRates{Interval: d, Size: a.Size, C: a.C}
+	rate := func(p, c uint64) float64 { return perSecond(p, c, 64, d) }
+
+	r.Hits = rate(prev.Hits, a.Hits)
+	r.Misses = rate(prev.Misses, a.Misses)
+	r.Reads = r.Hits + r.Misses
+	r.HitPct = pct(r.Hits, r.Reads)
+	r.MissPct = pct(r.Misses, r.Reads)
+
+	dh := rate(prev.DemandDataHits, a.DemandDataHits) + rate(prev.DemandMetadataHits, a.DemandMetadataHits)
+	dm := rate(prev.DemandDataMisses, a.DemandDataMisses) + rate(prev.DemandMetadataMisses, a.DemandMetadataMisses)
+	r.DemandReads = dh + dm
+	r.DemandHitPct = pct(dh, r.DemandReads)
+
+	ph := rate(prev.PrefetchDataHits, a.PrefetchDataHits) + rate(prev.PrefetchMetadataHits, a.PrefetchMetadataHits)
+	pm := rate(prev.PrefetchDataMisses, a.PrefetchDataMisses) + rate(prev.PrefetchMetadataMisses, a.PrefetchMetadataMisses)
+	r.PrefetchReads = ph + pm
+	r.PrefetchHitPct = pct(ph, r.PrefetchReads)
+
+	mh := rate(prev.DemandMetadataHits, a.DemandMetadataHits) + rate(prev.PrefetchMetadataHits, a.PrefetchMetadataHits)
+	mm := rate(prev.DemandMetadataMisses, a.DemandMetadataMisses) + rate(prev.PrefetchMetadataMisses, a.PrefetchMetadataMisses)
+	r.MetadataReads = mh + mm
+	r.MetadataHitPct = pct(mh, r.MetadataReads)
+
+	l2h := rate(prev.L2Hits, a.L2Hits)
+	r.L2Reads = l2h + rate(prev.L2Misses, a.L2Misses)
+	r.L2HitPct = pct(l2h, r.L2Reads)
+	r.L2ReadBytes = rate(prev.L2ReadBytes, a.L2ReadBytes)
+	return &r
+}
@@ -0,0 +1,18 @@
+//
+// Retrieving ArcStats.
+
+package kstat
+
+// ArcStats returns the current ZFS ARC statistics from zfs:0:arcstats.
+// It fails if ZFS is not loaded.
+func (t *Token) ArcStats() (*ArcStats, error) {
+	k, err := t.Lookup("zfs", 0, "arcstats")
+	if err != nil {
+		return nil, err
+	}
+	a := ArcStats{}
+	if err := k.fill(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
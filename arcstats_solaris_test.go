@@ -0,0 +1,23 @@
+//
+// Test retrieving ArcStats, which requires ZFS to be loaded.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+func TestArcStats(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	a, err := tok.ArcStats()
+	if err != nil {
+		t.Skipf("skipping test due to no ARC stats: %s", err)
+	}
+	if a.Snaptime == 0 || a.Size == 0 || a.CMax == 0 || a.CMin > a.CMax {
+		t.Fatalf("ARC stats are odd: %+v", a)
+	}
+	if a.Hits < a.DemandDataHits || a.Misses < a.DemandDataMisses {
+		t.Fatalf("ARC hits and misses are inconsistent: %+v", a)
+	}
+}
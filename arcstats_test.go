@@ -0,0 +1,78 @@
+//
+// Test ArcStats rate calculations.
+
+package kstat_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// near is true if a and b are equal to within floating point fuzz.
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestArcRates(t *testing.T) {
+	prev := &kstat.ArcStats{
+		Snaptime: 10 * int64(time.Second),
+		Hits:     1000, Misses: 100,
+		DemandDataHits: 500, DemandDataMisses: 50,
+		DemandMetadataHits: 300, DemandMetadataMisses: 10,
+		PrefetchDataHits: 150, PrefetchDataMisses: 30,
+		PrefetchMetadataHits: 50, PrefetchMetadataMisses: 10,
+		L2Hits: 5, L2Misses: 5,
+	}
+	cur := *prev
+	cur.Snaptime += 2 * int64(time.Second)
+	cur.Size, cur.C = 1<<30, 2<<30
+	// 180 hits and 20 misses over two seconds.
+	cur.Hits += 180
+	cur.Misses += 20
+	cur.DemandDataHits += 90
+	cur.DemandDataMisses += 10
+	cur.DemandMetadataHits += 60
+	cur.PrefetchDataHits += 30
+	cur.PrefetchDataMisses += 10
+	cur.L2Hits += 6
+	cur.L2Misses += 4
+	cur.L2ReadBytes += 2 << 20
+
+	r := cur.RatesSince(prev)
+	if r.Interval != 2*time.Second || r.Size != 1<<30 || r.C != 2<<30 {
+		t.Fatalf("bad interval or sizes: %+v", r)
+	}
+	if !near(r.Reads, 100) || !near(r.Hits, 90) || !near(r.HitPct, 90) || !near(r.MissPct, 10) {
+		t.Fatalf("bad overall rates: %+v", r)
+	}
+	if !near(r.DemandReads, 80) || !near(r.DemandHitPct, 93.75) {
+		t.Fatalf("bad demand rates: %+v", r)
+	}
+	if !near(r.PrefetchReads, 20) || !near(r.PrefetchHitPct, 75) {
+		t.Fatalf("bad prefetch rates: %+v", r)
+	}
+	if !near(r.MetadataReads, 30) || !near(r.MetadataHitPct, 100) {
+		t.Fatalf("bad metadata rates: %+v", r)
+	}
+	if !near(r.L2Reads, 5) || !near(r.L2HitPct, 60) || !near(r.L2ReadBytes, 1<<20) {
+		t.Fatalf("bad L2 rates: %+v", r)
+	}
+
+	// ARC counters are 64 bits, so one that goes backwards has
+	// been reset rather than wrapped around.
+	prev.Hits = 1000
+	cur.Hits = 20
+	r = cur.RatesSince(prev)
+	if !near(r.Hits, 10) {
+		t.Fatalf("bad rate for reset counter: %+v", r)
+	}
+
+	// No interval means no rates, not infinities.
+	r = prev.RatesSince(prev)
+	if r.Reads != 0 || r.HitPct != 0 {
+		t.Fatalf("zero interval gives non-zero rates: %+v", r)
+	}
+}
@@ -0,0 +1,59 @@
+//
+// Mapping kstat hrtimes (Crtime and Snaptime) to wall clock time.
+
+package kstat
+
+import "time"
+
+// A Clock maps hrtimes, such as KStat Crtimes and Snaptimes, to wall
+// clock time. hrtimes are nanoseconds since some arbitrary point
+// (see gethrtime(3C)), so a Clock is anchored by a single pair of an
+// hrtime and the wall clock time at that hrtime; everything else is
+// computed relative to the anchor.
+//
+// hrtime doesn't follow adjustments to the system's wall clock, so
+// over a long time a Clock may drift by however much the system
+// clock has been adjusted. Make a new one if this matters.
+type Clock struct {
+	hrtime int64
+	wall   time.Time
+
+	// Now returns the current wall clock time, for Age(). If it
+	// is nil, time.Now is used. Tests can set it to get a fixed
+	// time.
+	Now func() time.Time
+}
+
+// NewClock returns a Clock anchored so that hrtime is the wall clock
+// time wall.
+func NewClock(hrtime int64, wall time.Time) *Clock {
+	return &Clock{hrtime: hrtime, wall: wall}
+}
+
+// BootClock returns a Clock anchored on the system's boot time, for
+// example from SystemMisc.Boot(). This relies on hrtime starting
+// from zero at boot, as it does on illumos, and is only accurate to
+// a second since that is the resolution of boot_time. If you can,
+// use HRClock() on the system itself instead.
+func BootClock(boot time.Time) *Clock {
+	return NewClock(0, boot)
+}
+
+// Time returns the wall clock time of hrtime.
+func (c *Clock) Time(hrtime int64) time.Time {
+	return c.wall.Add(time.Duration(hrtime - c.hrtime))
+}
+
+// HRTime returns the hrtime of the wall clock time t.
+func (c *Clock) HRTime(t time.Time) int64 {
+	return c.hrtime + int64(t.Sub(c.wall))
+}
+
+// Age returns how long ago hrtime was.
+func (c *Clock) Age(hrtime int64) time.Duration {
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	return now().Sub(c.Time(hrtime))
+}
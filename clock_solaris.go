@@ -0,0 +1,74 @@
+//
+// Wall clock times for KStats and Nameds.
+
+package kstat
+
+// #include <sys/time.h>
+import "C"
+import "time"
+
+// HRClock returns a Clock anchored by reading gethrtime() and the
+// current time together.
+func HRClock() *Clock {
+	hr := int64(C.gethrtime())
+	return NewClock(hr, time.Now())
+}
+
+// Clock returns the Clock that the Token uses to convert hrtimes to
+// wall clock time for its KStats and Nameds. Unless you set one with
+// SetClock(), it is an HRClock() made the first time it's needed.
+func (t *Token) Clock() *Clock {
+	if t.clock == nil {
+		t.clock = HRClock()
+	}
+	return t.clock
+}
+
+// SetClock sets the Clock that the Token uses. This is mostly useful
+// for tests, or to re-anchor a Token's clock after the system's wall
+// clock has been adjusted.
+func (t *Token) SetClock(c *Clock) {
+	t.clock = c
+}
+
+// clock returns the Clock to use for the KStat's times. An invalid
+// KStat no longer has a Token, but its hrtimes are still good and it
+// keeps the Token's Clock from when it was invalidated. Something
+// that isn't a real KStat at all gets a fresh HRClock().
+func (k *KStat) clock() *Clock {
+	switch {
+	case k == nil:
+		return HRClock()
+	case k.tok != nil:
+		return k.tok.Clock()
+	case k.clk != nil:
+		return k.clk
+	default:
+		return HRClock()
+	}
+}
+
+// CrTime returns the wall clock time that the KStat was created at.
+// This works even if the KStat is no longer valid, although then it
+// uses a new HRClock() instead of its former Token's Clock.
+func (k *KStat) CrTime() time.Time {
+	return k.clock().Time(k.Crtime)
+}
+
+// SnapTime returns the wall clock time of the KStat's Snaptime, ie
+// when its data was last obtained. Like CrTime, it works on invalid
+// KStats.
+func (k *KStat) SnapTime() time.Time {
+	return k.clock().Time(k.Snaptime)
+}
+
+// Age returns how long ago the KStat's data was obtained.
+func (k *KStat) Age() time.Duration {
+	return k.clock().Age(k.Snaptime)
+}
+
+// SnapTime returns the wall clock time that the Named's value was
+// obtained at.
+func (n *Named) SnapTime() time.Time {
+	return n.KStat.clock().Time(n.Snaptime)
+}
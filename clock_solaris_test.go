@@ -0,0 +1,64 @@
+//
+// Test wall clock times for KStats.
+
+package kstat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestKStatTimes(t *testing.T) {
+	tok := start(t)
+	ks := lookup(t, tok, "unix", "system_misc")
+	n, err := ks.GetNamed("clk_intr")
+	if err != nil {
+		t.Fatalf("%s GetNamed clk_intr: %s", ks, err)
+	}
+	now := time.Now()
+	if d := now.Sub(ks.SnapTime()); d < 0 || d > time.Minute {
+		t.Fatalf("SnapTime is too far from now: %s vs %s", ks.SnapTime(), now)
+	}
+	if ks.CrTime().After(ks.SnapTime()) {
+		t.Fatalf("CrTime %s is after SnapTime %s", ks.CrTime(), ks.SnapTime())
+	}
+
+	// An injected clock is used.
+	wall := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := kstat.NewClock(ks.Snaptime, wall)
+	c.Now = func() time.Time { return wall.Add(time.Second) }
+	tok.SetClock(c)
+	if !ks.SnapTime().Equal(wall) || ks.Age() != time.Second {
+		t.Fatalf("injected clock not used: %s %s", ks.SnapTime(), ks.Age())
+	}
+	stop(t, tok)
+
+	// The injected clock is still used after the Token is closed.
+	if !ks.SnapTime().Equal(wall) || ks.Age() != time.Second {
+		t.Fatalf("injected clock not used after Close: %s %s", ks.SnapTime(), ks.Age())
+	}
+	if !n.SnapTime().Equal(c.Time(n.Snaptime)) {
+		t.Fatalf("injected clock not used for Named after Close: %s", n.SnapTime())
+	}
+
+	// So is the default clock.
+	tok = start(t)
+	ks = lookup(t, tok, "unix", "system_misc")
+	n, err = ks.GetNamed("clk_intr")
+	if err != nil {
+		t.Fatalf("%s GetNamed clk_intr: %s", ks, err)
+	}
+	stop(t, tok)
+	now = time.Now()
+	if d := now.Sub(ks.SnapTime()); d < 0 || d > time.Minute {
+		t.Fatalf("SnapTime after Close is too far from now: %s vs %s", ks.SnapTime(), now)
+	}
+	if ks.CrTime().After(ks.SnapTime()) || ks.Age() < 0 {
+		t.Fatalf("bad times after Close: %s %s %s", ks.CrTime(), ks.SnapTime(), ks.Age())
+	}
+	if d := now.Sub(n.SnapTime()); d < 0 || d > time.Minute {
+		t.Fatalf("Named SnapTime after Close is too far from now: %s vs %s", n.SnapTime(), now)
+	}
+}
@@ -0,0 +1,35 @@
+//
+// Test Clock conversions.
+
+package kstat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestClock(t *testing.T) {
+	wall := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := kstat.NewClock(int64(10*time.Second), wall)
+	if tm := c.Time(int64(12 * time.Second)); !tm.Equal(wall.Add(2 * time.Second)) {
+		t.Fatalf("wrong Time: %s", tm)
+	}
+	if tm := c.Time(0); !tm.Equal(wall.Add(-10 * time.Second)) {
+		t.Fatalf("wrong Time before the anchor: %s", tm)
+	}
+	if hr := c.HRTime(wall.Add(time.Minute)); hr != int64(70*time.Second) {
+		t.Fatalf("wrong HRTime: %d", hr)
+	}
+
+	c.Now = func() time.Time { return wall.Add(5 * time.Second) }
+	if a := c.Age(int64(10 * time.Second)); a != 5*time.Second {
+		t.Fatalf("wrong Age: %s", a)
+	}
+
+	b := kstat.BootClock(wall)
+	if tm := b.Time(int64(time.Hour)); !tm.Equal(wall.Add(time.Hour)) {
+		t.Fatalf("wrong BootClock Time: %s", tm)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !solaris
+// +build !solaris
+
+//
+// There are no live kstats on other platforms.
+
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func liveSnapshots(d time.Duration, sels []kstat.Selector) (*kstat.Snapshot, *kstat.Snapshot, error) {
+	return nil, nil, errors.New("live snapshots are only available on Solaris")
+}
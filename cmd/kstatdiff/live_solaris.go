@@ -0,0 +1,33 @@
+//
+// Live snapshots on Solaris.
+
+package main
+
+import (
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// liveSnapshots takes two snapshots of the kstats that sels select,
+// d apart.
+func liveSnapshots(d time.Duration, sels []kstat.Selector) (*kstat.Snapshot, *kstat.Snapshot, error) {
+	tok, err := kstat.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tok.Close()
+	before, err := tok.Snapshot(sels...)
+	if err != nil {
+		return nil, nil, err
+	}
+	time.Sleep(d)
+	if _, err := tok.Update(); err != nil {
+		return nil, nil, err
+	}
+	after, err := tok.Snapshot(sels...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
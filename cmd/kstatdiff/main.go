@@ -0,0 +1,179 @@
+// kstatdiff prints the differences between two kstat snapshots: the
+// kstats and statistics added and removed, the string statistics,
+// states, and gauges that changed, and the counters and time totals
+// ranked by how much they changed.
+//
+// Usage:
+//
+//	kstatdiff [options] RECORDING [RECORDING]
+//	kstatdiff [options] -live DURATION
+//
+// With one recording (made by a kstat.Recorder), it compares the
+// snapshots in it at the -from and -to times (by default, the first
+// and the last). With two, it compares the snapshot in the first at
+// -from (by default, its last) with the one in the second at -to (by
+// default, its last). With -live, it takes two snapshots of the
+// running system DURATION apart.
+//
+// Selectors given with -s (which may be repeated) limit the diff to
+// the kstats and statistics that they select, eg '-s zfs::arcstats'
+// or '-s link:::*bytes64'.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// selectors is a repeatable flag of kstat selectors.
+type selectors []kstat.Selector
+
+func (s *selectors) String() string {
+	var l []string
+	for _, sel := range *s {
+		l = append(l, sel.String())
+	}
+	return strings.Join(l, ",")
+}
+
+func (s *selectors) Set(v string) error {
+	sel, err := kstat.ParseSelector(v)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, sel)
+	return nil
+}
+
+// timeFlag is an optional time flag.
+type timeFlag struct {
+	t   time.Time
+	set bool
+}
+
+func (t *timeFlag) String() string {
+	if !t.set {
+		return ""
+	}
+	return t.t.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(v string) error {
+	tm, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return err
+	}
+	t.t, t.set = tm, true
+	return nil
+}
+
+// recording is a recording file being read.
+type recording struct {
+	name string
+	f    *os.File
+	rr   *kstat.RecordReader
+}
+
+func openRecording(fname string) (*recording, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := kstat.NewRecordReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %s", fname, err)
+	}
+	return &recording{fname, f, rr}, nil
+}
+
+// endOfTime is after every snapshot in any recording.
+var endOfTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// at returns the snapshot in the recording at t, or the first or last
+// one if t is not set. Since the recording is a file, its
+// RecordReader can go backwards to get an earlier snapshot.
+func (r *recording) at(t timeFlag, last bool) (*kstat.Snapshot, error) {
+	var snap *kstat.Snapshot
+	var err error
+	switch {
+	case t.set:
+		snap, err = r.rr.At(t.t)
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s: no snapshot at or before %s", r.name, t.t)
+		}
+	case last:
+		snap, err = r.rr.At(endOfTime)
+	default:
+		snap, err = r.rr.Next()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", r.name, err)
+	}
+	return snap, nil
+}
+
+// readAt returns the snapshot in the recording fname at t, or the
+// first or last one if t is not set.
+func readAt(fname string, t timeFlag, last bool) (*kstat.Snapshot, error) {
+	r, err := openRecording(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer r.f.Close()
+	return r.at(t, last)
+}
+
+func main() {
+	var sels selectors
+	var from, to timeFlag
+	log.SetPrefix("kstatdiff: ")
+	log.SetFlags(0)
+	flag.Var(&sels, "s", "only show kstats and statistics selected by `module:instance:name:stat` (may be repeated)")
+	flag.Var(&from, "from", "take the before snapshot at this RFC3339 `time`")
+	flag.Var(&to, "to", "take the after snapshot at this RFC3339 `time`")
+	live := flag.Duration("live", 0, "diff two live snapshots taken this `duration` apart")
+	top := flag.Int("n", 20, "show only the `N` largest counter and time changes (0 for all)")
+	flag.Parse()
+
+	var before, after *kstat.Snapshot
+	var err error
+	switch {
+	case *live > 0 && flag.NArg() == 0:
+		before, after, err = liveSnapshots(*live, sels)
+	case flag.NArg() == 1:
+		// Both snapshots are read with one RecordReader, which
+		// only goes back over the file if -to is before -from.
+		var r *recording
+		r, err = openRecording(flag.Arg(0))
+		if err == nil {
+			before, err = r.at(from, false)
+		}
+		if err == nil {
+			after, err = r.at(to, true)
+		}
+	case flag.NArg() == 2:
+		before, err = readAt(flag.Arg(0), from, true)
+		if err == nil {
+			after, err = readAt(flag.Arg(1), to, true)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d := kstat.DiffSnapshots(before, after).Filter(sels...)
+	if err := kstat.WriteDiff(os.Stdout, d, *top); err != nil {
+		log.Fatal(err)
+	}
+}
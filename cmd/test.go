@@ -23,6 +23,10 @@ func findNonNamed(tok *kstat.Token) {
 	for _, r := range tok.All() {
 		if !(r.Type == 1 || r.Type == 3) {
 			fmt.Printf("found: %s type=%d\n", r, r.Type)
+			// Show the ones that we know how to decode.
+			if v, err := r.Decode(); err == nil {
+				fmt.Printf("       %+v\n", v)
+			}
 		}
 	}
 }
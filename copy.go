@@ -0,0 +1,142 @@
+//
+// Support for copying semi-arbitrary structures out of raw kstat
+// data. This is used both by KStat.CopyTo() and friends and by the
+// decoders from StructDecoder() and SliceDecoder().
+
+package kstat
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// safeThing returns true if a given type is either a simple defined
+// size primitive integer or floating point type or an array and/or
+// struct composed entirely of safe things. A safe thing is entirely
+// self contained and may be initialized from random memory without
+// breaking Go's memory safety (although the values it contains may
+// be garbage).
+func safeThing(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Float32, reflect.Float64:
+		// Any bit pattern is a valid float, although some of
+		// them are NaNs.
+		return true
+	case reflect.Array:
+		// an array is safe if it's an array of something safe
+		return safeThing(t.Elem())
+	case reflect.Struct:
+		// a struct is safe if all its components are safe
+		for i := 0; i < t.NumField(); i++ {
+			if !safeThing(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		// other things are not safe.
+		return false
+	}
+}
+
+// safeStructPtr checks that ptr is a non-nil pointer to a safe struct
+// that can be copied into and returns the struct it points to. who is
+// the name of our caller, for panic messages.
+func safeStructPtr(ptr interface{}, who string) reflect.Value {
+	// Validity checks: not nil value, not nil pointer value,
+	// is a pointer to struct.
+	if ptr == nil {
+		panic(who + " given nil pointer")
+	}
+	vp := reflect.ValueOf(ptr)
+	if vp.Kind() != reflect.Ptr {
+		panic(who + " not given a pointer")
+	}
+	if vp.IsNil() {
+		panic(who + " given nil pointer")
+	}
+	dst := vp.Elem()
+	if dst.Kind() != reflect.Struct {
+		panic(who + ": not pointer to struct")
+	}
+	// Is the struct safe to copy into, which means primitive types
+	// and structs/arrays of primitive types?
+	if !safeThing(dst.Type()) {
+		panic(who + ": not a safe structure, contains unsupported fields")
+	}
+	if !dst.CanSet() {
+		panic(who + ": struct cannot be set for some reason")
+	}
+	return dst
+}
+
+// safeSlicePtr checks that ptr is a non-nil pointer to a slice of
+// some safe, non-zero sized thing and returns the slice it points to.
+// who is the name of our caller, for panic messages.
+func safeSlicePtr(ptr interface{}, who string) reflect.Value {
+	if ptr == nil {
+		panic(who + " given nil pointer")
+	}
+	vp := reflect.ValueOf(ptr)
+	if vp.Kind() != reflect.Ptr {
+		panic(who + " not given a pointer")
+	}
+	if vp.IsNil() {
+		panic(who + " given nil pointer")
+	}
+	dst := vp.Elem()
+	if dst.Kind() != reflect.Slice {
+		panic(who + ": not pointer to slice")
+	}
+	et := dst.Type().Elem()
+	if !safeThing(et) {
+		panic(who + ": not a safe slice element, contains unsupported fields")
+	}
+	if et.Size() == 0 {
+		panic(who + ": slice element has zero size")
+	}
+	if !dst.CanSet() {
+		panic(who + ": slice cannot be set for some reason")
+	}
+	return dst
+}
+
+// recordCount works out how many records of recsize bytes are in
+// kstat data of size bytes with the given ks_ndata. Variable sized
+// raw kstats normally set ks_ndata to the number of records, but
+// ordinary raw kstats have ks_ndata == ks_data_size; we accept
+// either, provided that the record size is right.
+func recordCount(size, ndata uint64, recsize uintptr) (int, error) {
+	rs := uint64(recsize)
+	switch {
+	case size == 0:
+		return 0, nil
+	case ndata != 0 && ndata != size && size%ndata == 0:
+		if size/ndata != rs {
+			return 0, fmt.Errorf("record size %d does not match element size %d", size/ndata, rs)
+		}
+		return int(ndata), nil
+	case size%rs != 0:
+		return 0, fmt.Errorf("data size %d is not a multiple of element size %d", size, rs)
+	default:
+		return int(size / rs), nil
+	}
+}
+
+// copyRecords sets dst, a slice, to a new slice of n elements copied
+// from the memory at src.
+func copyRecords(dst reflect.Value, src unsafe.Pointer, n int) {
+	ns := reflect.MakeSlice(dst.Type(), n, n)
+	if n > 0 {
+		// As in CopyTo, we turn src into a typed pointer, here
+		// to an array of n elements:
+		//
+		//	src := ((*[n]<type>)(src))
+		at := reflect.ArrayOf(n, dst.Type().Elem())
+		reflect.Copy(ns, reflect.NewAt(at, src).Elem())
+	}
+	dst.Set(ns)
+}
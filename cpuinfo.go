@@ -0,0 +1,241 @@
+//
+// Typed access to CPU inventory information from the
+// cpu_info:*:cpu_info* named kstats, and a CPU topology builder
+// with 'psrinfo -vp' style output.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CPUInfo is the information about one CPU (a virtual processor, in
+// psrinfo terms) from its cpu_info:<id>:cpu_info<id> kstat. Some
+// statistics are only present on some platforms; on SPARC, for
+// example, there is no vendor_id.
+type CPUInfo struct {
+	ID       int
+	Snaptime int64
+
+	Brand          string
+	VendorID       string
+	Implementation string
+	CPUType        string
+	FPUType        string
+	SocketType     string
+	Family         int
+	Model          int
+	Stepping       int
+
+	// The topology identifiers: the physical chip (socket), the
+	// core on the chip, the logical CPU ID on the chip, and the
+	// processor group.
+	ChipID int
+	CoreID int
+	ClogID int
+	PgID   int
+
+	NCPUPerChip  int
+	NCorePerChip int
+
+	ClockMHz       int64
+	CurrentClockHz uint64
+	// SupportedFrequencies are in Hz.
+	SupportedFrequencies []uint64
+
+	// State is eg 'on-line' or 'off-line', and StateBegin is when
+	// the CPU entered it.
+	State      string
+	StateBegin time.Time
+}
+
+// cpuInfoRaw is CPUInfo as it comes from the kstat, before the
+// conversion of the things that need it.
+type cpuInfoRaw struct {
+	ID       int   `kstat:"instance"`
+	Snaptime int64 `kstat:"snaptime"`
+
+	Brand          string `kstat:"brand"`
+	VendorID       string `kstat:"vendor_id"`
+	Implementation string `kstat:"implementation"`
+	CPUType        string `kstat:"cpu_type"`
+	FPUType        string `kstat:"fpu_type"`
+	SocketType     string `kstat:"socket_type"`
+	Family         int    `kstat:"family,gauge"`
+	Model          int    `kstat:"model,gauge"`
+	Stepping       int    `kstat:"stepping,gauge"`
+
+	ChipID int `kstat:"chip_id,gauge"`
+	CoreID int `kstat:"core_id,gauge"`
+	ClogID int `kstat:"clog_id,gauge"`
+	PgID   int `kstat:"pg_id,gauge"`
+
+	NCPUPerChip  int `kstat:"ncpu_per_chip,gauge"`
+	NCorePerChip int `kstat:"ncore_per_chip,gauge"`
+
+	ClockMHz       int64  `kstat:"clock_MHz,gauge"`
+	CurrentClockHz uint64 `kstat:"current_clock_Hz,gauge"`
+	SupportedFreqs string `kstat:"supported_frequencies_Hz"`
+
+	State      string `kstat:"state"`
+	StateBegin int64  `kstat:"state_begin,gauge"`
+}
+
+// parseFrequencies parses a supported_frequencies_Hz value, which
+// is a ':' separated list of frequencies. Anything unparseable is
+// skipped.
+func parseFrequencies(s string) []uint64 {
+	var res []uint64
+	for _, f := range strings.Split(s, ":") {
+		if n, err := strconv.ParseUint(strings.TrimSpace(f), 10, 64); err == nil {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func (r *cpuInfoRaw) cpuInfo() *CPUInfo {
+	c := CPUInfo{
+		ID: r.ID, Snaptime: r.Snaptime,
+		Brand: r.Brand, VendorID: r.VendorID, Implementation: r.Implementation,
+		CPUType: r.CPUType, FPUType: r.FPUType, SocketType: r.SocketType,
+		Family: r.Family, Model: r.Model, Stepping: r.Stepping,
+		ChipID: r.ChipID, CoreID: r.CoreID, ClogID: r.ClogID, PgID: r.PgID,
+		NCPUPerChip: r.NCPUPerChip, NCorePerChip: r.NCorePerChip,
+		ClockMHz: r.ClockMHz, CurrentClockHz: r.CurrentClockHz,
+		SupportedFrequencies: parseFrequencies(r.SupportedFreqs),
+		State:                r.State,
+	}
+	if r.StateBegin != 0 {
+		c.StateBegin = time.Unix(r.StateBegin, 0)
+	}
+	return &c
+}
+
+// Core is a CPU core and the virtual processors (CPUs) on it.
+type Core struct {
+	CoreID int
+	CPUs   []*CPUInfo
+}
+
+// Socket is a physical processor (chip) and its cores.
+type Socket struct {
+	ChipID int
+	Cores  []*Core
+}
+
+// CPUs returns all of the virtual processors in the socket, in ID
+// order.
+func (s *Socket) CPUs() []*CPUInfo {
+	var res []*CPUInfo
+	for _, c := range s.Cores {
+		res = append(res, c.CPUs...)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// Topology groups CPUs into sockets and cores by their ChipID and
+// CoreID. Sockets, cores, and CPUs are all sorted by their IDs.
+func Topology(cpus []*CPUInfo) []*Socket {
+	socks := make(map[int]*Socket)
+	cores := make(map[[2]int]*Core)
+	for _, c := range cpus {
+		s, ok := socks[c.ChipID]
+		if !ok {
+			s = &Socket{ChipID: c.ChipID}
+			socks[c.ChipID] = s
+		}
+		k := [2]int{c.ChipID, c.CoreID}
+		co, ok := cores[k]
+		if !ok {
+			co = &Core{CoreID: c.CoreID}
+			cores[k] = co
+			s.Cores = append(s.Cores, co)
+		}
+		co.CPUs = append(co.CPUs, c)
+	}
+
+	var res []*Socket
+	for _, s := range socks {
+		sort.Slice(s.Cores, func(i, j int) bool { return s.Cores[i].CoreID < s.Cores[j].CoreID })
+		for _, co := range s.Cores {
+			l := co.CPUs
+			sort.Slice(l, func(i, j int) bool { return l[i].ID < l[j].ID })
+		}
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ChipID < res[j].ChipID })
+	return res
+}
+
+// cpuList formats CPU IDs the way psrinfo does, as 'a-b' if they're a
+// contiguous range and a space separated list otherwise.
+func cpuList(cpus []*CPUInfo) string {
+	contig := len(cpus) > 1
+	ids := make([]string, len(cpus))
+	for i, c := range cpus {
+		ids[i] = strconv.Itoa(c.ID)
+		if i > 0 && c.ID != cpus[i-1].ID+1 {
+			contig = false
+		}
+	}
+	if contig {
+		return fmt.Sprintf("%d-%d", cpus[0].ID, cpus[len(cpus)-1].ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+func plural(n int, s string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, s)
+	}
+	return fmt.Sprintf("%d %ss", n, s)
+}
+
+// WritePsrinfoVP writes a description of sockets to w in the format
+// used by 'psrinfo -vp'.
+func WritePsrinfoVP(w io.Writer, sockets []*Socket) error {
+	var b strings.Builder
+	for _, s := range sockets {
+		cpus := s.CPUs()
+		if len(cpus) == 0 {
+			continue
+		}
+		smt := false
+		for _, co := range s.Cores {
+			if len(co.CPUs) > 1 {
+				smt = true
+			}
+		}
+		if len(s.Cores) > 1 {
+			fmt.Fprintf(&b, "The physical processor has %s and %s (%s)\n",
+				plural(len(s.Cores), "core"), plural(len(cpus), "virtual processor"), cpuList(cpus))
+		} else {
+			fmt.Fprintf(&b, "The physical processor has %s (%s)\n",
+				plural(len(cpus), "virtual processor"), cpuList(cpus))
+		}
+		if smt && len(s.Cores) > 1 {
+			for _, co := range s.Cores {
+				fmt.Fprintf(&b, "  The core has %s (%s)\n",
+					plural(len(co.CPUs), "virtual processor"), cpuList(co.CPUs))
+			}
+		}
+		// The implementation is eg 'x86 (chipid 0x0 GenuineIntel
+		// 306A9 family 6 model 58 step 9 clock 3400 MHz)';
+		// psrinfo leaves out the chipid.
+		c := cpus[0]
+		impl := strings.Replace(c.Implementation, fmt.Sprintf("(chipid 0x%x ", c.ChipID), "(", 1)
+		fmt.Fprintf(&b, "    %s\n", impl)
+		if c.Brand != "" {
+			fmt.Fprintf(&b, "      %s\n", c.Brand)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,51 @@
+//
+// Retrieving CPUInfo.
+
+package kstat
+
+import "syscall"
+
+// cpuInfo returns the CPUInfo for a cpu_info kstat.
+func (k *KStat) cpuInfo() (*CPUInfo, error) {
+	if err := k.Refresh(); err != nil {
+		return nil, err
+	}
+	r := cpuInfoRaw{}
+	if err := k.fill(&r); err != nil {
+		return nil, err
+	}
+	return r.cpuInfo(), nil
+}
+
+// CPUInfo returns the current information for a single CPU, from
+// cpu_info:<id>:cpu_info<id>.
+func (t *Token) CPUInfo(id int) (*CPUInfo, error) {
+	k, err := t.Lookup("cpu_info", id, "")
+	if err != nil {
+		return nil, err
+	}
+	return k.cpuInfo()
+}
+
+// CPUInfos returns the current information for every CPU in the
+// system, in kstat chain order (which is normally CPU ID order). Pass
+// the result to Topology() to group the CPUs into sockets and cores.
+// CPUs whose kstats have disappeared since the last Update() (for
+// example because they were removed by DR) are skipped.
+func (t *Token) CPUInfos() ([]*CPUInfo, error) {
+	var res []*CPUInfo
+	for _, k := range t.All() {
+		if k.Module != "cpu_info" || k.Type != NamedStat {
+			continue
+		}
+		c, err := k.cpuInfo()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
@@ -0,0 +1,34 @@
+//
+// Test retrieving CPUInfo.
+
+package kstat_test
+
+import (
+	"testing"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// As in TestNamedTypes, we assume that CPU 0 exists and is on-line.
+func TestCPUInfo(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	c, err := tok.CPUInfo(0)
+	if err != nil {
+		t.Fatalf("CPUInfo(0) error: %s", err)
+	}
+	if c.ID != 0 || c.State != "on-line" || c.StateBegin.IsZero() || c.Brand == "" || c.ClockMHz == 0 {
+		t.Fatalf("CPU 0 information is odd: %+v", c)
+	}
+	if len(c.SupportedFrequencies) == 0 || c.SupportedFrequencies[0] == 0 {
+		t.Fatalf("CPU 0 has bad supported frequencies: %v", c.SupportedFrequencies)
+	}
+
+	cl, err := tok.CPUInfos()
+	if err != nil {
+		t.Fatalf("CPUInfos error: %s", err)
+	}
+	if len(cl) == 0 || len(cl) < len(kstat.Topology(cl)) {
+		t.Fatalf("CPUInfos gave bad results: %+v", cl)
+	}
+}
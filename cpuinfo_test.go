@@ -0,0 +1,50 @@
+//
+// Test CPU topology building and 'psrinfo -vp' formatting.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func testCPUs() []*kstat.CPUInfo {
+	impl := "x86 (chipid 0x0 GenuineIntel 306A9 family 6 model 58 step 9 clock 3400 MHz)"
+	brand := "Intel(r) Core(tm) i7-3770 CPU @ 3.40GHz"
+	var cpus []*kstat.CPUInfo
+	// CPUs 0-1 are the first threads of cores 0 and 1, CPUs 2-3
+	// are the second threads, like Intel hyperthreading.
+	for id := 3; id >= 0; id-- {
+		cpus = append(cpus, &kstat.CPUInfo{ID: id, CoreID: id % 2, Implementation: impl, Brand: brand})
+	}
+	return cpus
+}
+
+func TestTopology(t *testing.T) {
+	socks := kstat.Topology(testCPUs())
+	if len(socks) != 1 || len(socks[0].Cores) != 2 {
+		t.Fatalf("bad topology: %+v", socks)
+	}
+	co := socks[0].Cores[1]
+	if co.CoreID != 1 || len(co.CPUs) != 2 || co.CPUs[0].ID != 1 || co.CPUs[1].ID != 3 {
+		t.Fatalf("bad core 1: %+v", co)
+	}
+	if l := socks[0].CPUs(); len(l) != 4 || l[0].ID != 0 || l[3].ID != 3 {
+		t.Fatalf("bad socket CPUs: %+v", l)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WritePsrinfoVP(&b, socks); err != nil {
+		t.Fatalf("WritePsrinfoVP error: %s", err)
+	}
+	exp := "The physical processor has 2 cores and 4 virtual processors (0-3)\n" +
+		"  The core has 2 virtual processors (0 2)\n" +
+		"  The core has 2 virtual processors (1 3)\n" +
+		"    x86 (GenuineIntel 306A9 family 6 model 58 step 9 clock 3400 MHz)\n" +
+		"      Intel(r) Core(tm) i7-3770 CPU @ 3.40GHz\n"
+	if b.String() != exp {
+		t.Fatalf("WritePsrinfoVP output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
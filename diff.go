@@ -0,0 +1,464 @@
+//
+// Comparing two Snapshots.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StatKind is what sort of number a statistic is, which determines
+// how a Diff reports changes in it.
+type StatKind int
+
+// The kinds of statistics. We know the kinds of the statistics of IO
+// kstats and of the named kstats that there are typed statistics
+// structs for (from their `kstat:"name,gauge"` and similar tags);
+// all other numeric named statistics are taken to be counters.
+const (
+	StatCounter   StatKind = iota // a count that only goes up
+	StatGauge                     // a current level, eg a queue length
+	StatNanos                     // a running total of nanoseconds
+	StatTimestamp                 // an hrtime, eg of the last update
+	StatState                     // an enumerated state, eg link_state
+)
+
+// ioStatKinds are the kinds of the Sample.Values() of an IO kstat
+// that aren't counters.
+var ioStatKinds = map[string]StatKind{
+	"wcnt": StatGauge, "rcnt": StatGauge,
+	"wtime": StatNanos, "wlentime": StatNanos,
+	"rtime": StatNanos, "rlentime": StatNanos,
+	"wlastupdate": StatTimestamp, "rlastupdate": StatTimestamp,
+}
+
+// typedKinds is the kinds of the statistics that aren't counters in
+// named kstats that are filled into typed statistics structs. name is
+// a path.Match() pattern.
+var typedKinds = []struct {
+	module, name string
+	kinds        map[string]StatKind
+}{
+	{"zfs", "arcstats", tagKinds(ArcStats{})},
+	{"unix", "system_pages", tagKinds(SystemPages{})},
+	{"unix", "system_misc", tagKinds(SystemMisc{})},
+	{"cpu_info", "*", tagKinds(cpuInfoRaw{})},
+	{"zones", "*", tagKinds(ZoneMisc{})},
+	{"memory_cap", "*", tagKinds(ZoneMemCap{})},
+	{"caps", "cpucaps_zone_*", tagKinds(ZoneCPUCap{})},
+	{"link", "*", tagKinds(LinkStats{})},
+	{"tcp", "tcp", tagKinds(TCPStats{})},
+	{"udp", "udp", tagKinds(UDPStats{})},
+	{"ip", "ip", tagKinds(IPStats{})},
+}
+
+// tagKinds returns the kinds of the statistics of the typed statistics
+// struct v that its tags mark as gauges or states.
+func tagKinds(v interface{}) map[string]StatKind {
+	vt := reflect.TypeOf(v)
+	kinds := make(map[string]StatKind)
+	for i := 0; i < vt.NumField(); i++ {
+		name, opts := statTag(vt.Field(i))
+		switch {
+		case hasOpt(opts, "gauge"):
+			kinds[name] = StatGauge
+		case hasOpt(opts, "state"):
+			kinds[name] = StatState
+		}
+	}
+	return kinds
+}
+
+// statKinds returns the kinds of s's statistics that aren't counters,
+// or nil if we don't know of any.
+func statKinds(s *Sample) map[string]StatKind {
+	if s.IO != nil {
+		return ioStatKinds
+	}
+	for _, t := range typedKinds {
+		if ok, _ := path.Match(t.name, s.Name); ok && t.module == s.Module {
+			return t.kinds
+		}
+	}
+	return nil
+}
+
+// A StatChange is a named statistic whose value differs between two
+// Snapshots, or that is only in one of them.
+type StatChange struct {
+	Module   string
+	Instance int
+	Name     string
+
+	// Old and New are the statistic's values. A statistic that
+	// has appeared has a zero Old, and one that has disappeared
+	// has a zero New.
+	Old NamedValue
+	New NamedValue
+
+	// Kind is what sort of statistic it is.
+	Kind StatKind
+
+	// Delta is New minus Old for numeric statistics (if their
+	// type hasn't changed), except that a counter that went
+	// backwards is taken to have wrapped around or been reset.
+	// Interval is the time between the kstat's two Snaptimes, for
+	// turning Delta into a rate.
+	Delta    float64
+	Interval time.Duration
+}
+
+// Stat returns the name of the statistic.
+func (c *StatChange) Stat() string {
+	if c.New.Name != "" {
+		return c.New.Name
+	}
+	return c.Old.Name
+}
+
+// Key returns the statistic as "module:instance:name:stat".
+func (c *StatChange) Key() string {
+	return fmt.Sprintf("%s:%d:%s:%s", c.Module, c.Instance, c.Name, c.Stat())
+}
+
+// Rate returns Delta per second over Interval, or 0 if the interval
+// isn't positive.
+func (c *StatChange) Rate() float64 {
+	if c.Interval <= 0 {
+		return 0
+	}
+	return c.Delta / c.Interval.Seconds()
+}
+
+// IsNumber returns true if the statistic is (now) numeric.
+func (c *StatChange) IsNumber() bool {
+	_, num := c.New.Number()
+	return num
+}
+
+// A Diff is the differences between two Snapshots.
+type Diff struct {
+	Before, After time.Time
+
+	// Added and Removed are the kstats that are only in the
+	// after or before Snapshot. A kstat that has been recreated
+	// (its Crtime differs) is both removed and added, since its
+	// statistics don't continue from the old one.
+	Added   []*Sample
+	Removed []*Sample
+
+	// Changed is every statistic whose value changed, for kstats
+	// in both Snapshots. IO kstats are compared using
+	// Sample.Values(), and raw kstats are not compared.
+	Changed []StatChange
+
+	// AddedStats and RemovedStats are the statistics that are only
+	// in the after or before Snapshot, for kstats in both.
+	AddedStats   []StatChange
+	RemovedStats []StatChange
+}
+
+// statDelta returns new minus old for numeric statistics of the given
+// kind. Counters that go backwards have wrapped around (if they're 32
+// bits) or been reset, as in counterDelta().
+func statDelta(o, n *NamedValue, kind StatKind) float64 {
+	bits := 64
+	if n.Type == Int32 || n.Type == Uint32 {
+		bits = 32
+	}
+	switch n.Type {
+	case Int32, Int64:
+		if kind == StatCounter {
+			return float64(counterDelta(uint64(o.IntVal), uint64(n.IntVal), bits))
+		}
+		return float64(n.IntVal - o.IntVal)
+	case Uint32, Uint64:
+		if kind == StatCounter {
+			return float64(counterDelta(o.UintVal, n.UintVal, bits))
+		}
+		return float64(int64(n.UintVal - o.UintVal))
+	}
+	return 0
+}
+
+// sortChanges sorts l by kstat, keeping statistics in their kstat's
+// order.
+func sortChanges(l []StatChange) {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := &l[i], &l[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.Instance != b.Instance {
+			return a.Instance < b.Instance
+		}
+		return a.Name < b.Name
+	})
+}
+
+// DiffSnapshots returns the differences between before and after.
+// The results are sorted by kstat and then statistic name, except
+// that the StatChanges keep statistics in their kstat's order.
+func DiffSnapshots(before, after *Snapshot) *Diff {
+	d := Diff{Before: before.Time, After: after.Time}
+	bm := make(map[string]*Sample, len(before.Samples))
+	for _, s := range before.Samples {
+		bm[s.Key()] = s
+	}
+	seen := make(map[string]bool, len(after.Samples))
+	for _, a := range after.Samples {
+		k := a.Key()
+		seen[k] = true
+		b, ok := bm[k]
+		if !ok || b.Crtime != a.Crtime {
+			d.Added = append(d.Added, a)
+			if ok {
+				d.Removed = append(d.Removed, b)
+			}
+			continue
+		}
+		kinds := statKinds(a)
+		change := func(o, n NamedValue) StatChange {
+			c := StatChange{Module: a.Module, Instance: a.Instance, Name: a.Name, Old: o, New: n,
+				Interval: snapInterval(b.Snaptime, a.Snaptime)}
+			c.Kind = kinds[c.Stat()]
+			return c
+		}
+
+		ov := make(map[string]*NamedValue)
+		bv := b.Values()
+		for i := range bv {
+			ov[bv[i].Name] = &bv[i]
+		}
+		av := a.Values()
+		for _, nv := range av {
+			o, ok := ov[nv.Name]
+			if !ok {
+				d.AddedStats = append(d.AddedStats, change(NamedValue{}, nv))
+				continue
+			}
+			delete(ov, nv.Name)
+			if *o == nv {
+				continue
+			}
+			c := change(*o, nv)
+			if o.Type == nv.Type {
+				c.Delta = statDelta(o, &nv, c.Kind)
+			}
+			d.Changed = append(d.Changed, c)
+		}
+		// What's left in ov has gone, but we want to report it
+		// in the kstat's order.
+		for i := range bv {
+			if _, ok := ov[bv[i].Name]; ok {
+				d.RemovedStats = append(d.RemovedStats, change(bv[i], NamedValue{}))
+			}
+		}
+	}
+	for _, b := range before.Samples {
+		if !seen[b.Key()] {
+			d.Removed = append(d.Removed, b)
+		}
+	}
+	sortSamples(d.Added)
+	sortSamples(d.Removed)
+	sortChanges(d.Changed)
+	sortChanges(d.AddedStats)
+	sortChanges(d.RemovedStats)
+	return &d
+}
+
+// filterChanges returns the changes in l that one of sels selects.
+func filterChanges(l []StatChange, sels []Selector) []StatChange {
+	var res []StatChange
+	for _, c := range l {
+		if c.matches(sels) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Filter returns a new Diff with only the kstats and statistics that
+// one of sels selects.
+func (d *Diff) Filter(sels ...Selector) *Diff {
+	nd := Diff{Before: d.Before, After: d.After}
+	for _, s := range d.Added {
+		if matchAny(sels, s.Module, s.Instance, s.Name) {
+			nd.Added = append(nd.Added, s)
+		}
+	}
+	for _, s := range d.Removed {
+		if matchAny(sels, s.Module, s.Instance, s.Name) {
+			nd.Removed = append(nd.Removed, s)
+		}
+	}
+	nd.Changed = filterChanges(d.Changed, sels)
+	nd.AddedStats = filterChanges(d.AddedStats, sels)
+	nd.RemovedStats = filterChanges(d.RemovedStats, sels)
+	return &nd
+}
+
+// matches returns true if any of sels selects c's statistic.
+func (c *StatChange) matches(sels []Selector) bool {
+	if len(sels) == 0 {
+		return true
+	}
+	for _, s := range sels {
+		if s.MatchStat(c.Module, c.Instance, c.Name, c.Stat()) {
+			return true
+		}
+	}
+	return false
+}
+
+// numbers returns the numeric changes of the given kind, in their
+// original order.
+func (d *Diff) numbers(kind StatKind) []StatChange {
+	var res []StatChange
+	for _, c := range d.Changed {
+		if c.IsNumber() && c.Kind == kind {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// byDelta sorts l by the size of their delta, largest first.
+func byDelta(l []StatChange) []StatChange {
+	sort.SliceStable(l, func(i, j int) bool { return math.Abs(l[i].Delta) > math.Abs(l[j].Delta) })
+	return l
+}
+
+// Numbers returns the changes in counters ranked by the size of their
+// delta, largest first. Gauges, running totals of nanoseconds,
+// timestamps, and states aren't counters and aren't included; see
+// Gauges(), Nanos(), and States().
+func (d *Diff) Numbers() []StatChange {
+	return byDelta(d.numbers(StatCounter))
+}
+
+// Gauges returns the changes in gauges, such as the wcnt and rcnt of
+// IO kstats, in kstat order.
+func (d *Diff) Gauges() []StatChange {
+	return d.numbers(StatGauge)
+}
+
+// States returns the changes in numeric states, such as the
+// link_state of datalinks, in kstat order.
+func (d *Diff) States() []StatChange {
+	return d.numbers(StatState)
+}
+
+// Nanos returns the changes in running totals of nanoseconds, such
+// as the rtime and wlentime of IO kstats, ranked by the size of their
+// delta, largest first.
+func (d *Diff) Nanos() []StatChange {
+	return byDelta(d.numbers(StatNanos))
+}
+
+// Strings returns the string statistics that changed, such as the
+// state of a CPU in its cpu_info kstat.
+func (d *Diff) Strings() []StatChange {
+	var res []StatChange
+	for _, c := range d.Changed {
+		if !c.IsNumber() {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// valueString formats a NamedValue's value.
+func valueString(n *NamedValue) string {
+	switch n.Type {
+	case Int32, Int64:
+		return fmt.Sprintf("%d", n.IntVal)
+	case Uint32, Uint64:
+		return fmt.Sprintf("%d", n.UintVal)
+	}
+	return fmt.Sprintf("%q", n.StringVal)
+}
+
+// WriteDiff writes d to w: the kstats and statistics added and
+// removed, the string statistics, states, and gauges that changed, the
+// counters ranked by the size of their change with their per-second
+// rate, and then the time totals ranked the same way with the
+// fraction of the time they cover. If n is positive, only the n
+// largest counter and time changes are listed. Timestamps are left
+// out.
+func WriteDiff(w io.Writer, d *Diff, n int) error {
+	var b strings.Builder
+	for _, s := range d.Added {
+		fmt.Fprintf(&b, "+ %s (%s)\n", s.Key(), s.Class)
+	}
+	for _, s := range d.Removed {
+		fmt.Fprintf(&b, "- %s (%s)\n", s.Key(), s.Class)
+	}
+	for _, c := range d.AddedStats {
+		fmt.Fprintf(&b, "+ %s = %s\n", c.Key(), valueString(&c.New))
+	}
+	for _, c := range d.RemovedStats {
+		fmt.Fprintf(&b, "- %s (was %s)\n", c.Key(), valueString(&c.Old))
+	}
+	if sl := d.Strings(); len(sl) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("changed strings:\n")
+		for _, c := range sl {
+			fmt.Fprintf(&b, "  %s %s -> %s\n", c.Key(), valueString(&c.Old), valueString(&c.New))
+		}
+	}
+	if sl := d.States(); len(sl) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("changed states:\n")
+		for _, c := range sl {
+			fmt.Fprintf(&b, "  %s %s -> %s\n", c.Key(), valueString(&c.Old), valueString(&c.New))
+		}
+	}
+	if gl := d.Gauges(); len(gl) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("changed gauges:\n")
+		for _, c := range gl {
+			fmt.Fprintf(&b, "  %s %s -> %s\n", c.Key(), valueString(&c.Old), valueString(&c.New))
+		}
+	}
+	top := func(l []StatChange) []StatChange {
+		if n > 0 && len(l) > n {
+			return l[:n]
+		}
+		return l
+	}
+	if nl := top(d.Numbers()); len(nl) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "largest changes over %s:\n", d.After.Sub(d.Before))
+		for _, c := range nl {
+			fmt.Fprintf(&b, "  %-50s %+16.0f %14.2f/s\n", c.Key(), c.Delta, c.Rate())
+		}
+	}
+	if tl := top(d.Nanos()); len(tl) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("largest time totals:\n")
+		for _, c := range tl {
+			fmt.Fprintf(&b, "  %-50s %16s %13.1f%%\n", c.Key(), time.Duration(c.Delta), 100*c.Rate()/1e9)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
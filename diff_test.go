@@ -0,0 +1,131 @@
+//
+// Test diffing Snapshots.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	link := func(state kstat.LinkState, rbytes uint64, snaptime int64, errs kstat.NamedValue) *kstat.Sample {
+		return &kstat.Sample{Module: "link", Name: "net0", Class: "net", Crtime: 5, Snaptime: snaptime, Named: []kstat.NamedValue{
+			{Name: "link_state", Type: kstat.Uint32, UintVal: uint64(state)},
+			{Name: "rbytes64", Type: kstat.Uint64, UintVal: rbytes},
+			errs,
+		}}
+	}
+	cpu := func(state string, hz uint64) *kstat.Sample {
+		return &kstat.Sample{Module: "cpu_info", Name: "cpu_info0", Class: "misc", Crtime: 1, Named: []kstat.NamedValue{
+			{Name: "state", Type: kstat.String, StringVal: state},
+			{Name: "current_clock_Hz", Type: kstat.Uint64, UintVal: hz},
+		}}
+	}
+	sec := int64(time.Second)
+	before := &kstat.Snapshot{Time: base, Samples: []*kstat.Sample{
+		link(kstat.LinkStateUp, 1000, sec, kstat.NamedValue{Name: "ierrors", Type: kstat.Uint32, UintVal: 3}),
+		cpu("on-line", 2000000000),
+		{Module: "sd", Name: "sd0", Class: "disk", Crtime: 1, Snaptime: sec,
+			IO: &kstat.IO{Nread: 100, Wcnt: 2, Rtime: sec, Wlastupdate: 5000 * sec}},
+		{Module: "sd", Instance: 1, Name: "sd1", Class: "disk", Crtime: 1},
+		{Module: "zfs", Name: "objset-0x5", Class: "dataset", Crtime: 1},
+	}}
+	// The link's kstat was read 5 seconds apart, not 10.
+	after := &kstat.Snapshot{Time: base.Add(10 * time.Second), Samples: []*kstat.Sample{
+		link(kstat.LinkStateDown, 51000, 6*sec, kstat.NamedValue{Name: "oerrors", Type: kstat.Uint32, UintVal: 2}),
+		cpu("no-intr", 800000000),
+		{Module: "sd", Name: "sd0", Class: "disk", Crtime: 1, Snaptime: 11 * sec,
+			IO: &kstat.IO{Nread: 200, Wcnt: 1, Rtime: 6 * sec, Wlastupdate: 9000 * sec}},
+		{Module: "sd", Instance: 1, Name: "sd1", Class: "disk", Crtime: 2},
+		{Module: "zfs", Name: "objset-0x9", Class: "dataset", Crtime: 3},
+	}}
+
+	d := kstat.DiffSnapshots(before, after)
+	if len(d.Added) != 2 || d.Added[0].Key() != "sd:1:sd1" || d.Added[1].Key() != "zfs:0:objset-0x9" {
+		t.Fatalf("wrong added kstats: %+v", d.Added)
+	}
+	if len(d.Removed) != 2 || d.Removed[0].Key() != "sd:1:sd1" || d.Removed[1].Key() != "zfs:0:objset-0x5" {
+		t.Fatalf("wrong removed kstats: %+v", d.Removed)
+	}
+	if len(d.AddedStats) != 1 || d.AddedStats[0].Key() != "link:0:net0:oerrors" ||
+		len(d.RemovedStats) != 1 || d.RemovedStats[0].Key() != "link:0:net0:ierrors" || d.RemovedStats[0].Old.UintVal != 3 {
+		t.Fatalf("wrong added or removed statistics: %+v %+v", d.AddedStats, d.RemovedStats)
+	}
+	// Timestamps such as wlastupdate, states such as link_state,
+	// and gauges aren't ranked, and rates are over each kstat's
+	// Snaptimes.
+	nl := d.Numbers()
+	if len(nl) != 2 || nl[0].Key() != "link:0:net0:rbytes64" || nl[0].Delta != 50000 || nl[0].Rate() != 10000 ||
+		nl[1].Key() != "sd:0:sd0:nread" || nl[1].Rate() != 10 {
+		t.Fatalf("wrong numeric changes: %+v", nl)
+	}
+	if gl := d.Gauges(); len(gl) != 2 || gl[0].Key() != "cpu_info:0:cpu_info0:current_clock_Hz" ||
+		gl[1].Key() != "sd:0:sd0:wcnt" || gl[1].Delta != -1 {
+		t.Fatalf("wrong gauge changes: %+v", gl)
+	}
+	if tl := d.Nanos(); len(tl) != 1 || tl[0].Key() != "sd:0:sd0:rtime" || tl[0].Delta != float64(5*sec) {
+		t.Fatalf("wrong time changes: %+v", tl)
+	}
+	sl := d.Strings()
+	if len(sl) != 1 || sl[0].Old.StringVal != "on-line" || sl[0].New.StringVal != "no-intr" {
+		t.Fatalf("wrong string changes: %+v", sl)
+	}
+	if sl := d.States(); len(sl) != 1 || sl[0].Key() != "link:0:net0:link_state" || sl[0].New.UintVal != uint64(kstat.LinkStateDown) {
+		t.Fatalf("wrong state changes: %+v", sl)
+	}
+
+	sel, _ := kstat.ParseSelectors("link:::*bytes64", "zfs")
+	fd := d.Filter(sel...)
+	if len(fd.Added) != 1 || len(fd.Removed) != 1 || len(fd.Changed) != 1 || fd.Changed[0].New.Name != "rbytes64" {
+		t.Fatalf("wrong filtered diff: %+v", fd)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WriteDiff(&b, d, 2); err != nil {
+		t.Fatalf("WriteDiff error: %s", err)
+	}
+	exp := "+ sd:1:sd1 (disk)\n" +
+		"+ zfs:0:objset-0x9 (dataset)\n" +
+		"- sd:1:sd1 (disk)\n" +
+		"- zfs:0:objset-0x5 (dataset)\n" +
+		"+ link:0:net0:oerrors = 2\n" +
+		"- link:0:net0:ierrors (was 3)\n" +
+		"\nchanged strings:\n" +
+		"  cpu_info:0:cpu_info0:state \"on-line\" -> \"no-intr\"\n" +
+		"\nchanged states:\n" +
+		"  link:0:net0:link_state 1 -> 0\n" +
+		"\nchanged gauges:\n" +
+		"  cpu_info:0:cpu_info0:current_clock_Hz 2000000000 -> 800000000\n" +
+		"  sd:0:sd0:wcnt 2 -> 1\n" +
+		"\nlargest changes over 10s:\n" +
+		"  link:0:net0:rbytes64                                         +50000       10000.00/s\n" +
+		"  sd:0:sd0:nread                                                 +100          10.00/s\n" +
+		"\nlargest time totals:\n" +
+		"  sd:0:sd0:rtime                                                   5s          50.0%\n"
+	if b.String() != exp {
+		t.Fatalf("WriteDiff output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
+
+func TestDiffCounterWrap(t *testing.T) {
+	smp := func(wrap, big, reset uint64) *kstat.Snapshot {
+		return &kstat.Snapshot{Samples: []*kstat.Sample{{Module: "fake", Name: "fake", Named: []kstat.NamedValue{
+			{Name: "wrap", Type: kstat.Uint32, UintVal: wrap},
+			{Name: "big", Type: kstat.Uint64, UintVal: big},
+			{Name: "reset", Type: kstat.Uint64, UintVal: reset},
+		}}}}
+	}
+	// A 32-bit counter that wraps around has gone up a little, not
+	// down a lot, and a 64-bit one that goes down has been reset.
+	d := kstat.DiffSnapshots(smp(0xfffffff0, 1000, 1<<40), smp(0x10, 1500, 10))
+	nl := d.Numbers()
+	if len(nl) != 3 || nl[0].Stat() != "big" || nl[0].Delta != 500 || nl[1].Stat() != "wrap" || nl[1].Delta != 0x20 ||
+		nl[2].Stat() != "reset" || nl[2].Delta != 10 {
+		t.Fatalf("wrong counter changes: %+v", nl)
+	}
+}
@@ -0,0 +1,97 @@
+//
+// Typed access to disk inventory and error counters from the
+// <driver>err:<instance>:<driver><instance>,err named kstats (eg
+// sderr:0:sd0,err), correlated with the disk's IO kstat, plus an
+// 'iostat -En' style formatter.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DiskInfo is the inventory information for a disk from its error
+// kstat. The strings come from the device's SCSI inquiry data (or
+// the equivalent) and so may be blank.
+type DiskInfo struct {
+	Vendor   string `kstat:"Vendor"`
+	Product  string `kstat:"Product"`
+	Revision string `kstat:"Revision"`
+	Serial   string `kstat:"Serial No"`
+	// Size is the size of the disk in bytes.
+	Size uint64 `kstat:"Size"`
+}
+
+// DiskErrors is the error counters for a disk from its error kstat.
+type DiskErrors struct {
+	Soft              uint64 `kstat:"Soft Errors"`
+	Hard              uint64 `kstat:"Hard Errors"`
+	Transport         uint64 `kstat:"Transport Errors"`
+	Media             uint64 `kstat:"Media Error"`
+	DeviceNotReady    uint64 `kstat:"Device Not Ready"`
+	NoDevice          uint64 `kstat:"No Device"`
+	Recoverable       uint64 `kstat:"Recoverable"`
+	IllegalRequest    uint64 `kstat:"Illegal Request"`
+	PredictiveFailure uint64 `kstat:"Predictive Failure Analysis"`
+}
+
+// Total returns the total of the soft, hard, and transport errors,
+// which are the summary counts; the other counters are breakdowns.
+func (e DiskErrors) Total() uint64 {
+	return e.Soft + e.Hard + e.Transport
+}
+
+// Disk is everything we know about a disk from its error kstat and
+// its IO kstat.
+type Disk struct {
+	// Module, Instance, and Name identify the disk's IO kstat,
+	// eg sd:0:sd0. Name is what iostat calls the disk if it
+	// can't find a /dev/dsk name for it.
+	Module   string
+	Instance int
+	Name     string
+
+	Info   DiskInfo
+	Errors DiskErrors
+	// ErrSnaptime is the Snaptime of the error kstat.
+	ErrSnaptime int64
+
+	// IO is the disk's IO statistics, or nil if it has no IO
+	// kstat. IOSnaptime is the Snaptime of the IO kstat.
+	IO         *IO
+	IOSnaptime int64
+}
+
+// errKStatIO returns the module and name of the IO kstat that
+// corresponds to an error kstat module and name, eg sd and sd0 for
+// sderr and 'sd0,err'. ok is false if module and name are not those
+// of an error kstat.
+func errKStatIO(module, name string) (iomod, ioname string, ok bool) {
+	if !strings.HasSuffix(module, "err") || !strings.HasSuffix(name, ",err") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(module, "err"), strings.TrimSuffix(name, ",err"), true
+}
+
+// WriteIostatEn writes information about disks to w in the format
+// used by 'iostat -En'.
+func WriteIostatEn(w io.Writer, disks []*Disk) error {
+	var b strings.Builder
+	for _, d := range disks {
+		e, i := d.Errors, d.Info
+		fmt.Fprintf(&b, "%-16s Soft Errors: %d Hard Errors: %d Transport Errors: %d\n",
+			d.Name, e.Soft, e.Hard, e.Transport)
+		fmt.Fprintf(&b, "Vendor: %-8s Product: %-16s Revision: %-4s Serial No: %s\n",
+			strings.TrimSpace(i.Vendor), strings.TrimSpace(i.Product),
+			strings.TrimSpace(i.Revision), strings.TrimSpace(i.Serial))
+		fmt.Fprintf(&b, "Size: %.2fGB <%d bytes>\n", float64(i.Size)/1e9, i.Size)
+		fmt.Fprintf(&b, "Media Error: %d Device Not Ready: %d No Device: %d Recoverable: %d\n",
+			e.Media, e.DeviceNotReady, e.NoDevice, e.Recoverable)
+		fmt.Fprintf(&b, "Illegal Request: %d Predictive Failure Analysis: %d\n",
+			e.IllegalRequest, e.PredictiveFailure)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,64 @@
+//
+// Retrieving Disks.
+
+package kstat
+
+import "syscall"
+
+// Disks returns information on every disk that has an error kstat
+// (eg sderr:0:sd0,err), together with its IO statistics if it has an
+// IO kstat (eg sd:0:sd0). The disks are in kstat chain order, which
+// is normally instance order. All kstats are refreshed as they're
+// read. Disks whose error kstats have disappeared since the last
+// Update() are skipped, and ones whose IO kstats have disappeared
+// have no IO statistics.
+func (t *Token) Disks() ([]*Disk, error) {
+	all := t.All()
+	ios := make(map[string]*KStat)
+	for _, k := range all {
+		if k.Type == IoStat {
+			ios[k.Module+":"+k.Name] = k
+		}
+	}
+
+	var res []*Disk
+	for _, k := range all {
+		if k.Type != NamedStat {
+			continue
+		}
+		iomod, ioname, ok := errKStatIO(k.Module, k.Name)
+		if !ok {
+			continue
+		}
+		err := k.Refresh()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d := Disk{Module: iomod, Instance: k.Instance, Name: ioname, ErrSnaptime: k.Snaptime}
+		if err := k.fill(&d.Info); err != nil {
+			return nil, err
+		}
+		if err := k.fill(&d.Errors); err != nil {
+			return nil, err
+		}
+		if ik, ok := ios[iomod+":"+ioname]; ok {
+			io, err := ik.GetIO()
+			switch {
+			case err == syscall.ENXIO:
+				// The IO kstat has gone away, so we
+				// have no IO statistics.
+			case err != nil:
+				return nil, err
+			default:
+				d.Instance = ik.Instance
+				d.IO = io
+				d.IOSnaptime = ik.Snaptime
+			}
+		}
+		res = append(res, &d)
+	}
+	return res, nil
+}
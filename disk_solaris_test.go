@@ -0,0 +1,28 @@
+//
+// Test retrieving Disks.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+// Like other tests, we assume that sd0 exists and is the boot disk.
+func TestDisks(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	dl, err := tok.Disks()
+	if err != nil {
+		t.Fatalf("Disks error: %s", err)
+	}
+	for _, d := range dl {
+		if d.Name != "sd0" {
+			continue
+		}
+		if d.Module != "sd" || d.IO == nil || d.IO.Reads == 0 || d.ErrSnaptime == 0 {
+			t.Fatalf("sd0 disk information is odd: %+v", d)
+		}
+		return
+	}
+	t.Fatalf("no sd0 in Disks: %+v", dl)
+}
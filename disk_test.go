@@ -0,0 +1,35 @@
+//
+// Test 'iostat -En' formatting.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestWriteIostatEn(t *testing.T) {
+	d := &kstat.Disk{
+		Module: "sd", Instance: 0, Name: "sd0",
+		Info: kstat.DiskInfo{Vendor: "VMware  ", Product: "Virtual disk    ", Revision: "1.0 ", Size: 17179869184},
+		Errors: kstat.DiskErrors{Soft: 1, Hard: 2, Transport: 3, IllegalRequest: 6,
+			Recoverable: 1},
+	}
+	var b bytes.Buffer
+	if err := kstat.WriteIostatEn(&b, []*kstat.Disk{d}); err != nil {
+		t.Fatalf("WriteIostatEn error: %s", err)
+	}
+	exp := "sd0              Soft Errors: 1 Hard Errors: 2 Transport Errors: 3\n" +
+		"Vendor: VMware   Product: Virtual disk     Revision: 1.0  Serial No: \n" +
+		"Size: 17.18GB <17179869184 bytes>\n" +
+		"Media Error: 0 Device Not Ready: 0 No Device: 0 Recoverable: 1\n" +
+		"Illegal Request: 6 Predictive Failure Analysis: 0\n"
+	if b.String() != exp {
+		t.Fatalf("WriteIostatEn output wrong:\n%q\nexpected:\n%q", b.String(), exp)
+	}
+	if d.Errors.Total() != 6 {
+		t.Fatalf("bad error total: %d", d.Errors.Total())
+	}
+}
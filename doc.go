@@ -80,14 +80,36 @@
 //
 // https://utcc.utoronto.ca/~cks/space/blog/programming/GoCGoCompatibleStructs
 //
+// Once you have a Go struct, you can register a decoder for the raw
+// kstat with RegisterDecoder() (StructDecoder() will make you one
+// from the struct). KStat.Decode() then returns a decoded value for
+// any raw kstat with a registered decoder, including the ones that
+// the package supports directly, so generic code that dumps or
+// prints kstats picks up your new raw types without changes.
+//
+// Raw data saved from another machine (perhaps a big-endian SPARC
+// one) can be decoded with the pure Go DecodeIO(), DecodeSysinfo(),
+// etc functions or with the Layout for that platform.
+//
 // Author: Chris Siebenmann
 // https://github.com/siebenmann/go-kstat
 //
 // Copyright: standard Go copyright.
 //
+// The statistics types (IO, Sysinfo, Vminfo, Var, Mntinfo, and so on),
+// the KSType and NamedType constants, and the pure Go helpers and
+// decoders build on every platform, so code that merely passes them
+// around doesn't need build tags. Everything that actually talks to
+// the kernel (Token, KStat, Named, and Raw) is Solaris-only.
+//
+// Token.Snapshot() takes a detached Snapshot of the kstats picked
+// out by Selectors (kstat(1) style module:instance:name:stat
+// patterns), and a Sampler takes them periodically. Snapshots and
+// their Samples are plain data and are also usable everywhere.
+//
 // (If you're reading this documentation on a non-Solaris platform,
 // you're probably not seeing the detailed API documentation for
-// constants, types, and so on because of tooling limitations in godoc
+// Token, KStat, and so on because of tooling limitations in godoc
 // et al.)
 //
 package kstat
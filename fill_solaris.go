@@ -0,0 +1,105 @@
+//
+// Filling in typed statistics structs from named kstats.
+
+package kstat
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// fill fills in the struct that dst points to from the named
+// statistics of k, which must be a named kstat. Fields are matched
+// to statistics by their `kstat:"name"` tags (see statTag()). As with
+// kstat(1), the pseudo-statistics "module", "instance", "name",
+// "class", "crtime", and "snaptime" come from k itself. Statistics
+// without a field and fields without a statistic are both ignored,
+// because the exact set of statistics in a kstat varies between OS
+// versions.
+//
+// Integer fields accept both signed and unsigned statistics, and
+// string fields accept both String and CharData ones. A statistic
+// of the wrong sort for its field is an error.
+//
+// fill does not refresh k.
+func (k *KStat) fill(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		panic("fill not given a pointer to struct")
+	}
+	v = v.Elem()
+	lst, err := k.AllNamed()
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string]reflect.Value)
+	vt := v.Type()
+	for i := 0; i < vt.NumField(); i++ {
+		if name, _ := statTag(vt.Field(i)); name != "" {
+			fields[name] = v.Field(i)
+		}
+	}
+
+	// The pseudo-statistics.
+	pseudo := []*Named{
+		{Name: "module", Type: String, StringVal: k.Module},
+		{Name: "instance", Type: Int32, IntVal: int64(k.Instance)},
+		{Name: "name", Type: String, StringVal: k.Name},
+		{Name: "class", Type: String, StringVal: k.Class},
+		{Name: "crtime", Type: Int64, IntVal: k.Crtime},
+		{Name: "snaptime", Type: Int64, IntVal: k.Snaptime},
+	}
+	for _, n := range append(pseudo, lst...) {
+		f, ok := fields[n.Name]
+		if !ok {
+			continue
+		}
+		if err := setField(f, n); err != nil {
+			return fmt.Errorf("kstat %s statistic %s: %s", k, n.Name, err)
+		}
+	}
+	return nil
+}
+
+// lookupFill looks up the named kstat module:instance:name and fills
+// in dst from it.
+func (t *Token) lookupFill(module string, instance int, name string, dst interface{}) error {
+	k, err := t.Lookup(module, instance, name)
+	if err != nil {
+		return err
+	}
+	return k.fill(dst)
+}
+
+// setField sets f from the value of n.
+func setField(f reflect.Value, n *Named) error {
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch n.Type {
+		case Uint32, Uint64:
+			f.SetUint(n.UintVal)
+		case Int32, Int64:
+			f.SetUint(uint64(n.IntVal))
+		default:
+			return fmt.Errorf("%s statistic for %s field", n.Type, f.Type())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch n.Type {
+		case Uint32, Uint64:
+			f.SetInt(int64(n.UintVal))
+		case Int32, Int64:
+			f.SetInt(n.IntVal)
+		default:
+			return fmt.Errorf("%s statistic for %s field", n.Type, f.Type())
+		}
+	case reflect.String:
+		if n.Type != String && n.Type != CharData {
+			return fmt.Errorf("%s statistic for string field", n.Type)
+		}
+		f.SetString(n.StringVal)
+	default:
+		panic(fmt.Sprintf("fill: unsupported field type %s", f.Type()))
+	}
+	return nil
+}
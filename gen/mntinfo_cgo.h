@@ -33,7 +33,7 @@
  * Our crude solution is to create a variant version with the struct
  * type made non-anonymous. We can use cgo to convert, and then in
  * this case I reversed the out-of-lining of the struct type and
- * verified that the re-inlined version (in types_solaris_amd64.go)
+ * verified that the re-inlined version (in types.go)
  * is the same size as the C version and presumably the same alignment.
  */
 
@@ -0,0 +1,136 @@
+//
+// Test that the functions that gather typed statistics from many
+// kstats skip kstats that disappear out from under them.
+//
+// Unlike our other tests, this is an internal test, because we need
+// to get at the kstat_t to fake a kstat disappearing.
+
+package kstat
+
+import (
+	"strings"
+	"testing"
+)
+
+// vanish makes k look like a kstat that has been deleted from the
+// kernel since the kstat chain was read, so that reading it fails
+// with ENXIO (the kernel can no longer find its kid). It returns a
+// function that undoes this.
+func vanish(k *KStat) func() {
+	kid := k.ksp.ks_kid
+	k.ksp.ks_kid = -1
+	return func() { k.ksp.ks_kid = kid }
+}
+
+// Each test gives a kstat to make vanish and a function to count
+// how many things are gathered; one less thing should be gathered
+// once it's gone.
+var goneTests = []struct {
+	what  string
+	match func(k *KStat) bool
+	count func(t *Token) (int, error)
+}{
+	{"Datasets", (*KStat).isObjset, func(t *Token) (int, error) {
+		l, err := t.Datasets()
+		return len(l), err
+	}},
+	{"Links", func(k *KStat) bool { return k.Module == "link" && k.Type == NamedStat }, func(t *Token) (int, error) {
+		l, err := t.Links()
+		return len(l), err
+	}},
+	{"Disks", func(k *KStat) bool {
+		_, _, ok := errKStatIO(k.Module, k.Name)
+		return ok && k.Type == NamedStat
+	}, func(t *Token) (int, error) {
+		l, err := t.Disks()
+		return len(l), err
+	}},
+	{"CPUInfos", func(k *KStat) bool { return k.Module == "cpu_info" && k.Type == NamedStat }, func(t *Token) (int, error) {
+		l, err := t.CPUInfos()
+		return len(l), err
+	}},
+	// A zone has several kstats, so losing its zones kstat only
+	// loses its Misc statistics.
+	{"Zones", func(k *KStat) bool { return k.Module == "zones" && k.Type == NamedStat }, func(t *Token) (int, error) {
+		m, err := t.Zones()
+		n := 0
+		for _, z := range m {
+			if z.Misc != nil {
+				n++
+			}
+		}
+		return n, err
+	}},
+	{"KmemCaches", func(k *KStat) bool {
+		return k.Type == NamedStat && k.Module == "unix" && k.Class == "kmem_cache"
+	}, func(t *Token) (int, error) {
+		l, err := t.KmemCaches()
+		return len(l), err
+	}},
+	{"VopStats", func(k *KStat) bool {
+		return k.Type == NamedStat && k.Module == "unix" && strings.HasPrefix(k.Name, "vopstats_")
+	}, func(t *Token) (int, error) {
+		l, err := t.VopStats()
+		return len(l), err
+	}},
+	{"NFSClientStats", func(k *KStat) bool {
+		return k.Type == NamedStat && k.Module == "nfs" && k.Instance == 0 && strings.HasPrefix(k.Name, "rfsreqcnt_v")
+	}, func(t *Token) (int, error) {
+		s, err := t.NFSClientStats()
+		if err != nil {
+			return 0, err
+		}
+		return len(s.Ops), nil
+	}},
+	{"IntrStats", func(k *KStat) bool {
+		return k.Type == NamedStat && k.Module == "cpu" && k.Name == "intrstat"
+	}, func(t *Token) (int, error) {
+		l, err := t.IntrStats()
+		return len(l), err
+	}},
+	{"Taskqs", func(k *KStat) bool {
+		return k.Type == NamedStat && (k.Class == "taskq" || k.Class == "taskq_d")
+	}, func(t *Token) (int, error) {
+		l, err := t.Taskqs()
+		return len(l), err
+	}},
+}
+
+// We can't make the kernel delete a kstat on demand, so we fake it
+// with vanish(). Not every system has every sort of kstat; we skip
+// the ones that are missing.
+func TestGoneKStats(t *testing.T) {
+	tok, err := Open()
+	if err != nil {
+		t.Fatalf("Open failure: %s", err)
+	}
+	defer tok.Close()
+
+	for _, gt := range goneTests {
+		var victim *KStat
+		for _, k := range tok.All() {
+			if gt.match(k) {
+				victim = k
+				break
+			}
+		}
+		if victim == nil {
+			t.Logf("%s: no kstats to test with", gt.what)
+			continue
+		}
+
+		n, err := gt.count(tok)
+		if err != nil {
+			t.Fatalf("%s failed: %s", gt.what, err)
+		}
+		restore := vanish(victim)
+		n2, err := gt.count(tok)
+		restore()
+		if err != nil {
+			t.Fatalf("%s failed with %s gone: %s", gt.what, victim, err)
+		}
+		if n2 != n-1 {
+			t.Fatalf("%s gave %d with %s gone, expected %d", gt.what, n2, victim, n-1)
+		}
+	}
+}
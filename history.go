@@ -0,0 +1,255 @@
+//
+// History is an in-memory store of recent numeric statistics from
+// Snapshots, with range queries and rate calculations.
+
+package kstat
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Point is the value of a statistic at a time.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// A Series is the points of a single statistic, in time order. Key
+// is "module:instance:name:stat".
+type Series struct {
+	Key    string
+	Points []Point
+}
+
+// point is how points are stored internally; a time.Time is three
+// times the size of a Unix time in nanoseconds.
+type point struct {
+	t int64
+	v float64
+}
+
+// ring is a growable ring buffer of points.
+type ring struct {
+	buf   []point
+	start int
+	n     int
+}
+
+func (r *ring) at(i int) point {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+func (r *ring) push(p point) {
+	if r.n == len(r.buf) {
+		nb := make([]point, 2*len(r.buf)+8)
+		for i := 0; i < r.n; i++ {
+			nb[i] = r.at(i)
+		}
+		r.buf, r.start = nb, 0
+	}
+	r.buf[(r.start+r.n)%len(r.buf)] = p
+	r.n++
+}
+
+func (r *ring) pop() point {
+	p := r.at(0)
+	r.start = (r.start + 1) % len(r.buf)
+	r.n--
+	return p
+}
+
+// series is the stored history of a single statistic. Points move
+// from fine to coarse as they age, being downsampled on the way.
+type series struct {
+	module   string
+	instance int
+	name     string
+	stat     string
+
+	fine   ring
+	coarse ring
+}
+
+func (s *series) key() string {
+	return fmt.Sprintf("%s:%d:%s:%s", s.module, s.instance, s.name, s.stat)
+}
+
+// newest returns the time of the series' newest point, which is in
+// coarse if everything in fine has aged out, and false if it has no
+// points.
+func (s *series) newest() (int64, bool) {
+	switch {
+	case s.fine.n > 0:
+		return s.fine.at(s.fine.n - 1).t, true
+	case s.coarse.n > 0:
+		return s.coarse.at(s.coarse.n - 1).t, true
+	default:
+		return 0, false
+	}
+}
+
+// History keeps the recent history of the numeric statistics in the
+// Snapshots added to it. Points from the last Fine duration are kept
+// at full resolution. Older points are downsampled to one point (the
+// last one) per Coarse period, which preserves counter rates over
+// those periods, and points older than Retention are dropped. This
+// caps memory use at roughly Fine/(sample interval) plus
+// Retention/Coarse points per statistic.
+//
+// A History is safe for concurrent use, so it can be fed by a
+// Sampler while it's being queried.
+type History struct {
+	Fine      time.Duration
+	Coarse    time.Duration
+	Retention time.Duration
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+// NewHistory returns a History with the given settings. It panics if
+// they are not positive or if retention is shorter than fine.
+func NewHistory(fine, coarse, retention time.Duration) *History {
+	if fine <= 0 || coarse <= 0 || retention < fine {
+		panic("NewHistory given bad durations")
+	}
+	return &History{Fine: fine, Coarse: coarse, Retention: retention,
+		series: make(map[string]*series)}
+}
+
+// Add adds the numeric statistics of all of the samples in snap.
+// Points are timestamped with their sample's Time. Points must be
+// added in time order; ones that are not newer than the last point
+// of their statistic are ignored.
+func (h *History) Add(snap *Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var newest int64
+	for _, smp := range snap.Samples {
+		t := smp.Time.UnixNano()
+		if t > newest {
+			newest = t
+		}
+		for _, nv := range smp.Values() {
+			v, ok := nv.Number()
+			if !ok {
+				continue
+			}
+			k := fmt.Sprintf("%s:%d:%s:%s", smp.Module, smp.Instance, smp.Name, nv.Name)
+			s := h.series[k]
+			if s == nil {
+				s = &series{module: smp.Module, instance: smp.Instance, name: smp.Name, stat: nv.Name}
+				h.series[k] = s
+			}
+			if last, ok := s.newest(); ok && last >= t {
+				continue
+			}
+			s.fine.push(point{t, v})
+		}
+	}
+	h.age(newest)
+}
+
+// age downsamples and drops points relative to the time now.
+func (h *History) age(now int64) {
+	fineCut := now - int64(h.Fine)
+	dropCut := now - int64(h.Retention)
+	coarse := int64(h.Coarse)
+	for k, s := range h.series {
+		for s.fine.n > 0 && s.fine.at(0).t < fineCut {
+			p := s.fine.pop()
+			// Replace the last coarse point if it's in the
+			// same period, so we keep the last point in each.
+			if s.coarse.n > 0 && s.coarse.at(s.coarse.n-1).t/coarse == p.t/coarse {
+				s.coarse.buf[(s.coarse.start+s.coarse.n-1)%len(s.coarse.buf)] = p
+			} else {
+				s.coarse.push(p)
+			}
+		}
+		for s.coarse.n > 0 && s.coarse.at(0).t < dropCut {
+			s.coarse.pop()
+		}
+		if s.fine.n == 0 && s.coarse.n == 0 {
+			delete(h.series, k)
+		}
+	}
+}
+
+// Keys returns the keys of all statistics that sel selects, sorted.
+func (h *History) Keys(sel Selector) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var res []string
+	for k, s := range h.series {
+		if sel.MatchStat(s.module, s.instance, s.name, s.stat) {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// points returns the points of s between from and to, inclusive.
+func (s *series) points(from, to int64) []Point {
+	var res []Point
+	for _, r := range []*ring{&s.coarse, &s.fine} {
+		for i := 0; i < r.n; i++ {
+			if p := r.at(i); p.t >= from && p.t <= to {
+				res = append(res, Point{time.Unix(0, p.t), p.v})
+			}
+		}
+	}
+	return res
+}
+
+// Query returns the points between from and to (inclusive) of every
+// statistic that sel selects, sorted by key.
+func (h *History) Query(sel Selector, from, to time.Time) []*Series {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var res []*Series
+	for k, s := range h.series {
+		if !sel.MatchStat(s.module, s.instance, s.name, s.stat) {
+			continue
+		}
+		if pl := s.points(from.UnixNano(), to.UnixNano()); len(pl) > 0 {
+			res = append(res, &Series{Key: k, Points: pl})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
+
+// Rates treats the statistics that sel selects as counters and
+// returns their per-second rates between from and to. Each point
+// in a result is the rate over the window ending at that point,
+// computed from the oldest point that is no more than window before
+// it; with a zero window, it's the rate since the previous point.
+// Points where the counter went backwards (because it was reset)
+// are skipped.
+func (h *History) Rates(sel Selector, from, to time.Time, window time.Duration) []*Series {
+	var res []*Series
+	for _, s := range h.Query(sel, from, to) {
+		r := Series{Key: s.Key}
+		j := 0
+		for i := 1; i < len(s.Points); i++ {
+			cur := s.Points[i]
+			for j < i-1 && cur.Time.Sub(s.Points[j].Time) > window {
+				j++
+			}
+			prev := s.Points[j]
+			d := cur.Time.Sub(prev.Time)
+			if d <= 0 || cur.Value < prev.Value {
+				continue
+			}
+			r.Points = append(r.Points, Point{cur.Time, (cur.Value - prev.Value) / d.Seconds()})
+		}
+		if len(r.Points) > 0 {
+			res = append(res, &r)
+		}
+	}
+	return res
+}
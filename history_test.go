@@ -0,0 +1,109 @@
+//
+// Test the History store.
+
+package kstat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// histSnap makes a snapshot with a counter, a gauge, a string, and an
+// IO kstat at time t.
+func histSnap(t time.Time, count uint64, gauge int64) *kstat.Snapshot {
+	return &kstat.Snapshot{Time: t, Samples: []*kstat.Sample{
+		{Module: "tcp", Name: "tcp", Time: t, Named: []kstat.NamedValue{
+			{Name: "inSegs", Type: kstat.Uint64, UintVal: count},
+			{Name: "maxConn", Type: kstat.Int32, IntVal: gauge},
+			{Name: "class", Type: kstat.String, StringVal: "mib2"},
+		}},
+		{Module: "sd", Instance: 1, Name: "sd1", Time: t, IO: &kstat.IO{Nread: 2 * count}},
+	}}
+}
+
+func TestHistory(t *testing.T) {
+	base := time.Unix(1000, 0)
+	h := kstat.NewHistory(10*time.Second, 5*time.Second, time.Minute)
+	for i := 0; i < 60; i++ {
+		h.Add(histSnap(base.Add(time.Duration(i)*time.Second), uint64(i*100), -1))
+	}
+	end := base.Add(59 * time.Second)
+
+	all, _ := kstat.ParseSelector("")
+	keys := h.Keys(all)
+	exp := []string{"sd:1:sd1:nread", "sd:1:sd1:nwritten"}
+	if len(keys) != 14 || keys[0] != exp[0] || keys[1] != exp[1] {
+		t.Fatalf("wrong keys: %v", keys)
+	}
+
+	sel, _ := kstat.ParseSelector("tcp:0:tcp:inSegs")
+	sl := h.Query(sel, base, end)
+	if len(sl) != 1 || sl[0].Key != "tcp:0:tcp:inSegs" {
+		t.Fatalf("wrong query result: %+v", sl)
+	}
+	// The last 10 seconds (11 points) are kept at full resolution
+	// and the rest are downsampled to the last point in each 5
+	// seconds.
+	pl := sl[0].Points
+	if len(pl) != 11+10 || pl[9].Value != 4800 || !pl[len(pl)-1].Time.Equal(end) || pl[len(pl)-1].Value != 5900 {
+		t.Fatalf("wrong points: %+v", pl)
+	}
+	if !pl[0].Time.Equal(base.Add(4*time.Second)) || !pl[1].Time.Equal(base.Add(9*time.Second)) {
+		t.Fatalf("wrong downsampled points: %+v", pl[:2])
+	}
+
+	// Rates.
+	rl := h.Rates(sel, end.Add(-5*time.Second), end, 0)
+	if len(rl) != 1 || len(rl[0].Points) != 5 || !near(rl[0].Points[0].Value, 100) {
+		t.Fatalf("wrong rates: %+v", rl)
+	}
+	rsel, _ := kstat.ParseSelector("sd:::nread")
+	rl = h.Rates(rsel, base, end, 10*time.Second)
+	if len(rl) != 1 || !near(rl[0].Points[len(rl[0].Points)-1].Value, 200) {
+		t.Fatalf("wrong windowed IO rates: %+v", rl)
+	}
+
+	// Everything ages out eventually.
+	h.Add(histSnap(end.Add(2*time.Minute), 0, -1))
+	if sl := h.Query(sel, base, end); len(sl) != 0 {
+		t.Fatalf("old points not dropped: %+v", sl)
+	}
+}
+
+func TestHistoryResets(t *testing.T) {
+	base := time.Unix(1000, 0)
+	h := kstat.NewHistory(time.Minute, time.Minute, time.Hour)
+	for i, c := range []uint64{100, 200, 50, 150} {
+		h.Add(histSnap(base.Add(time.Duration(i)*time.Second), c, 0))
+	}
+	// Out of order points are ignored.
+	h.Add(histSnap(base, 1000, 0))
+
+	sel, _ := kstat.ParseSelector("tcp:::inSegs")
+	rl := h.Rates(sel, base, base.Add(time.Minute), 0)
+	if len(rl) != 1 || len(rl[0].Points) != 2 || rl[0].Points[0].Value != 100 || rl[0].Points[1].Value != 100 {
+		t.Fatalf("wrong rates across a reset: %+v", rl)
+	}
+}
+
+// Out of order points are ignored even once a statistic's recent
+// points have all been downsampled.
+func TestHistoryOutOfOrderCoarse(t *testing.T) {
+	base := time.Unix(1000, 0)
+	h := kstat.NewHistory(10*time.Second, 5*time.Second, time.Minute)
+	h.Add(histSnap(base, 100, 0))
+	// Only the sd kstat is sampled for a while, so the tcp points
+	// age out of the fine history.
+	sd := histSnap(base.Add(30*time.Second), 200, 0)
+	sd.Samples = sd.Samples[1:]
+	h.Add(sd)
+	h.Add(histSnap(base.Add(-time.Second), 50, 0))
+
+	sel, _ := kstat.ParseSelector("tcp:::inSegs")
+	sl := h.Query(sel, base.Add(-time.Minute), base.Add(time.Minute))
+	if len(sl) != 1 || len(sl[0].Points) != 1 || !sl[0].Points[0].Time.Equal(base) {
+		t.Fatalf("out of order point added after downsampling: %+v", sl)
+	}
+}
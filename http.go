@@ -0,0 +1,329 @@
+//
+// Serving kstats as JSON over HTTP.
+//
+// The API is:
+//
+//	GET /kstats				the headers of every kstat
+//	GET /kstats/module/instance/name	one kstat's Sample
+//	GET /kstats/module/instance/name/stat	one named statistic
+//	GET /select?s=selector&s=...		a Snapshot of what the selectors select
+//
+// In kstat paths, a module or name of "*" or an instance of -1
+// matches anything, as with an empty module or name in Token.Lookup().
+// The statistics of IO kstats can be fetched individually under the
+// names that Sample.Values() uses.
+
+package kstat
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Source is somewhere that kstats can be served from. Both Tokens
+// (on Solaris) and Snapshots are Sources.
+type Source interface {
+	// Headers returns Samples with only the identifying
+	// information of every kstat, not their data.
+	Headers() ([]*Sample, error)
+	// Snapshot returns a Snapshot of the kstats and statistics
+	// that the selectors select, or all of them if there are no
+	// selectors.
+	Snapshot(sels ...Selector) (*Snapshot, error)
+}
+
+// Headers returns the identifying information of every sample in s.
+func (s *Snapshot) Headers() ([]*Sample, error) {
+	res := make([]*Sample, len(s.Samples))
+	for i, smp := range s.Samples {
+		res[i] = smp.header()
+	}
+	return res, nil
+}
+
+// Snapshot returns s.Select(sels...), so that a Snapshot can be used
+// as a Source.
+func (s *Snapshot) Snapshot(sels ...Selector) (*Snapshot, error) {
+	return s.Select(sels...), nil
+}
+
+// header returns a copy of s without its data.
+func (s *Sample) header() *Sample {
+	return &Sample{Module: s.Module, Instance: s.Instance, Name: s.Name, Class: s.Class,
+		Type: s.Type, Crtime: s.Crtime, Snaptime: s.Snaptime, Time: s.Time}
+}
+
+// globQuote quotes the glob metacharacters in s, returning "" (which
+// matches anything) if s is empty.
+func globQuote(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// lookupSelector returns a Selector that selects module:instance:name
+// in the way that Token.Lookup() does, and optionally a single
+// statistic.
+func lookupSelector(module string, instance int, name, stat string) Selector {
+	sel := Selector{Module: globQuote(module), Name: globQuote(name), Stat: globQuote(stat)}
+	if instance != -1 {
+		sel.Instance = strconv.Itoa(instance)
+	}
+	return sel
+}
+
+// Handler is an http.Handler that serves kstats from a Source as
+// JSON. It serializes requests to its Source, since Tokens are not
+// safe for concurrent use. If the Source has an Update() method, as
+// Tokens do, it's called before each request so that new kstats are
+// seen.
+type Handler struct {
+	src Source
+	mu  sync.Mutex
+}
+
+// NewHandler returns a Handler serving src. Mount it at the root of
+// a server or use http.StripPrefix.
+func NewHandler(src Source) *Handler {
+	return &Handler{src: src}
+}
+
+// httpError is an error with an HTTP status.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+// apiError is the JSON body of error responses.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var v interface{}
+	var err error
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		err = &httpError{http.StatusMethodNotAllowed, "method not allowed"}
+	} else {
+		h.mu.Lock()
+		v, err = h.serve(r)
+		h.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if he, ok := err.(*httpError); ok {
+			status = he.status
+		}
+		w.WriteHeader(status)
+		v = apiError{err.Error()}
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+// serve does the actual work of a request, returning what to encode
+// as the response.
+func (h *Handler) serve(r *http.Request) (interface{}, error) {
+	if u, ok := h.src.(interface {
+		Update() (bool, error)
+	}); ok {
+		if _, err := u.Update(); err != nil {
+			return nil, err
+		}
+	}
+
+	p := strings.Trim(r.URL.EscapedPath(), "/")
+	switch {
+	case p == "kstats":
+		return h.src.Headers()
+	case p == "select":
+		sels, err := ParseSelectors(r.URL.Query()["s"]...)
+		if err != nil {
+			return nil, &httpError{http.StatusBadRequest, err.Error()}
+		}
+		return h.src.Snapshot(sels...)
+	case strings.HasPrefix(p, "kstats/"):
+		return h.serveKStat(strings.Split(p, "/")[1:])
+	}
+	return nil, &httpError{http.StatusNotFound, "no such API endpoint"}
+}
+
+// serveKStat serves /kstats/module/instance/name[/stat].
+func (h *Handler) serveKStat(parts []string) (interface{}, error) {
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, &httpError{http.StatusNotFound, "kstat paths are module/instance/name[/stat]"}
+	}
+	for i, p := range parts {
+		up, err := url.PathUnescape(p)
+		if err != nil {
+			return nil, &httpError{http.StatusBadRequest, err.Error()}
+		}
+		if up == "*" {
+			up = ""
+		}
+		parts[i] = up
+	}
+	inst, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, &httpError{http.StatusBadRequest, "bad instance " + strconv.Quote(parts[1])}
+	}
+	stat := ""
+	if len(parts) == 4 {
+		stat = parts[3]
+		if stat == "" {
+			return nil, &httpError{http.StatusBadRequest, "empty statistic name"}
+		}
+	}
+
+	snap, err := h.src.Snapshot(lookupSelector(parts[0], inst, parts[2], stat))
+	if err != nil {
+		return nil, err
+	}
+	if len(snap.Samples) == 0 {
+		return nil, &httpError{http.StatusNotFound, "no such kstat"}
+	}
+	s := snap.Samples[0]
+	if stat == "" {
+		return s, nil
+	}
+	// IO kstats have statistics too, under the names that
+	// Sample.Values() gives them.
+	for _, nv := range s.Values() {
+		if nv.Name == stat {
+			return nv, nil
+		}
+	}
+	return nil, &httpError{http.StatusNotFound, "no such statistic"}
+}
+
+// Client is a client for the API served by a Handler, offering the
+// same sort of lookups as a Token.
+type Client struct {
+	// URL is the base URL of the Handler.
+	URL string
+	// HTTP is the http.Client to use; if it is nil,
+	// http.DefaultClient is used.
+	HTTP *http.Client
+}
+
+// NewClient returns a Client for the Handler at baseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{URL: strings.TrimRight(baseURL, "/")}
+}
+
+// get fetches path (which is already escaped) and decodes the JSON
+// response into v.
+func (c *Client) get(path string, v interface{}) error {
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Get(c.URL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var ae apiError
+		if json.NewDecoder(resp.Body).Decode(&ae) != nil || ae.Error == "" {
+			ae.Error = resp.Status
+		}
+		return errors.New(ae.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// kstatPath returns the escaped API path of a kstat.
+func kstatPath(module string, instance int, name string) string {
+	if module == "" {
+		module = "*"
+	}
+	if name == "" {
+		name = "*"
+	}
+	return "/kstats/" + url.PathEscape(module) + "/" + strconv.Itoa(instance) + "/" + url.PathEscape(name)
+}
+
+// Headers returns the identifying information of every kstat. A
+// Client is itself a Source, so you can serve a remote host's kstats
+// again.
+func (c *Client) Headers() ([]*Sample, error) {
+	var res []*Sample
+	if err := c.get("/kstats", &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Lookup returns the current Sample of the kstat module:instance:name.
+// As with Token.Lookup(), an empty module or name or an instance of
+// -1 match anything.
+func (c *Client) Lookup(module string, instance int, name string) (*Sample, error) {
+	var s Sample
+	if err := c.get(kstatPath(module, instance, name), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetNamed returns the current value of a named statistic, or of one
+// of the statistics of an IO kstat (eg "nread").
+func (c *Client) GetNamed(module string, instance int, name, stat string) (*NamedValue, error) {
+	var nv NamedValue
+	if err := c.get(kstatPath(module, instance, name)+"/"+url.PathEscape(stat), &nv); err != nil {
+		return nil, err
+	}
+	return &nv, nil
+}
+
+// AllNamed returns the current values of all of the named statistics
+// of a named kstat.
+func (c *Client) AllNamed(module string, instance int, name string) ([]NamedValue, error) {
+	s, err := c.Lookup(module, instance, name)
+	if err != nil {
+		return nil, err
+	}
+	if s.Type != NamedStat {
+		return nil, errors.New("kstat " + s.Key() + " is not a named kstat")
+	}
+	return s.Named, nil
+}
+
+// GetIO returns the current IO statistics of an IO kstat.
+func (c *Client) GetIO(module string, instance int, name string) (*IO, error) {
+	s, err := c.Lookup(module, instance, name)
+	if err != nil {
+		return nil, err
+	}
+	if s.IO == nil {
+		return nil, errors.New("kstat " + s.Key() + " is not an IO kstat")
+	}
+	return s.IO, nil
+}
+
+// Snapshot returns a Snapshot of the kstats and statistics that sels
+// select.
+func (c *Client) Snapshot(sels ...Selector) (*Snapshot, error) {
+	q := url.Values{}
+	for _, s := range sels {
+		q.Add("s", s.String())
+	}
+	var snap Snapshot
+	if err := c.get("/select?"+q.Encode(), &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
@@ -0,0 +1,28 @@
+//
+// Test serving a Token over HTTP.
+
+package kstat_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestHTTPToken(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	srv := httptest.NewServer(kstat.NewHandler(tok))
+	defer srv.Close()
+	c := kstat.NewClient(srv.URL)
+
+	nv, err := c.GetNamed("unix", 0, "system_misc", "ncpus")
+	if err != nil || nv.UintVal == 0 {
+		t.Fatalf("GetNamed wrong: %+v %v", nv, err)
+	}
+	hl, err := c.Headers()
+	if err != nil || len(hl) != len(tok.All()) {
+		t.Fatalf("Headers wrong: %d headers, %v", len(hl), err)
+	}
+}
@@ -0,0 +1,119 @@
+//
+// Test the HTTP Handler and Client.
+
+package kstat_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func testServer(t *testing.T) (*httptest.Server, *kstat.Snapshot) {
+	tm := time.Unix(1500000000, 0).UTC()
+	snap := &kstat.Snapshot{Time: tm, Samples: []*kstat.Sample{
+		{Module: "sd", Instance: 0, Name: "sd0", Class: "disk", Type: kstat.IoStat, Snaptime: 7, Time: tm,
+			IO: &kstat.IO{Nread: 4096, Reads: 1}},
+		{Module: "unix", Instance: 0, Name: "system_misc", Class: "misc", Type: kstat.NamedStat, Snaptime: 8, Time: tm,
+			Named: []kstat.NamedValue{
+				{Name: "ncpus", Type: kstat.Uint32, UintVal: 4},
+				{Name: "deficit", Type: kstat.Int32, IntVal: -2},
+			}},
+		{Module: "zfs", Instance: 0, Name: "objset-0x[1]", Class: "dataset", Type: kstat.NamedStat, Time: tm,
+			Named: []kstat.NamedValue{{Name: "dataset_name", Type: kstat.String, StringVal: "rpool/a b"}}},
+	}}
+	return httptest.NewServer(kstat.NewHandler(snap)), snap
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv, snap := testServer(t)
+	defer srv.Close()
+	c := kstat.NewClient(srv.URL + "/")
+
+	hl, err := c.Headers()
+	if err != nil {
+		t.Fatalf("Headers error: %s", err)
+	}
+	if len(hl) != 3 || hl[1].Key() != "unix:0:system_misc" || hl[1].Named != nil {
+		t.Fatalf("wrong headers: %+v", hl)
+	}
+
+	s, err := c.Lookup("unix", 0, "system_misc")
+	if err != nil {
+		t.Fatalf("Lookup error: %s", err)
+	}
+	if !reflect.DeepEqual(s, snap.Samples[1]) {
+		t.Fatalf("Lookup result wrong:\n%+v\nexpected:\n%+v", s, snap.Samples[1])
+	}
+	if s, err := c.Lookup("", -1, "sd0"); err != nil || s.Module != "sd" {
+		t.Fatalf("wildcard Lookup wrong: %+v %v", s, err)
+	}
+	if s, err := c.Lookup("zfs", 0, "objset-0x[1]"); err != nil || s.Named[0].StringVal != "rpool/a b" {
+		t.Fatalf("Lookup of glob-like name wrong: %+v %v", s, err)
+	}
+	if _, err := c.Lookup("zfs", 0, "objset-0x1"); err == nil || err.Error() != "no such kstat" {
+		t.Fatalf("Lookup of missing kstat gave %v", err)
+	}
+
+	nv, err := c.GetNamed("unix", 0, "system_misc", "deficit")
+	if err != nil || nv.IntVal != -2 || nv.Type != kstat.Int32 {
+		t.Fatalf("GetNamed wrong: %+v %v", nv, err)
+	}
+	if _, err := c.GetNamed("unix", 0, "system_misc", "nproc"); err == nil {
+		t.Fatalf("GetNamed of missing statistic did not fail")
+	}
+	nv, err = c.GetNamed("sd", 0, "sd0", "nread")
+	if err != nil || nv.UintVal != 4096 || nv.Type != kstat.Uint64 {
+		t.Fatalf("GetNamed of IO statistic wrong: %+v %v", nv, err)
+	}
+	nl, err := c.AllNamed("unix", 0, "system_misc")
+	if err != nil || len(nl) != 2 {
+		t.Fatalf("AllNamed wrong: %+v %v", nl, err)
+	}
+	if _, err := c.AllNamed("sd", 0, "sd0"); err == nil {
+		t.Fatalf("AllNamed of IO kstat did not fail")
+	}
+	io, err := c.GetIO("sd", 0, "sd0")
+	if err != nil || *io != *snap.Samples[0].IO {
+		t.Fatalf("GetIO wrong: %+v %v", io, err)
+	}
+
+	sel, _ := kstat.ParseSelectors("unix:::ncpus", "sd")
+	ns, err := c.Snapshot(sel...)
+	if err != nil {
+		t.Fatalf("Snapshot error: %s", err)
+	}
+	if len(ns.Samples) != 2 || len(ns.Samples[1].Named) != 1 || !ns.Time.Equal(snap.Time) {
+		t.Fatalf("Snapshot wrong: %+v", ns)
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	srv, _ := testServer(t)
+	defer srv.Close()
+	for _, c := range []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/nothing", http.StatusNotFound},
+		{"GET", "/kstats/unix/x/system_misc", http.StatusBadRequest},
+		{"GET", "/kstats/unix/0", http.StatusNotFound},
+		{"GET", "/select?s=a:b:c:d:e", http.StatusBadRequest},
+		{"POST", "/kstats", http.StatusMethodNotAllowed},
+	} {
+		req, _ := http.NewRequest(c.method, srv.URL+c.path, strings.NewReader(""))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error: %s", c.method, c.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status || resp.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("%s %s gave %s, expected %d", c.method, c.path, resp.Status, c.status)
+		}
+	}
+}
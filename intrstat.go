@@ -0,0 +1,107 @@
+//
+// Typed access to the per-CPU interrupt statistics in the
+// cpu:N:intrstat kstats, plus an intrstat-like view of how much CPU
+// time goes to each interrupt level.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxPIL is the highest interrupt priority level.
+const MaxPIL = 15
+
+// IntrStats is the interrupt statistics of a single CPU, indexed by
+// interrupt priority level (PIL). Count is how many interrupts there
+// have been at each level and Time is how much time has been spent
+// handling them, in nanoseconds. Index 0 is unused.
+type IntrStats struct {
+	CPU      int
+	Snaptime int64
+	Count    [MaxPIL + 1]uint64
+	Time     [MaxPIL + 1]uint64
+}
+
+// IntrRates is a CPU's interrupt activity over an interval, indexed
+// by interrupt priority level. Rate is interrupts per second and Pct
+// is the percentage of the CPU's time spent handling them.
+type IntrRates struct {
+	CPU      int
+	Interval time.Duration
+	Rate     [MaxPIL + 1]float64
+	Pct      [MaxPIL + 1]float64
+}
+
+// TotalPct returns the total percentage of the CPU's time spent
+// handling interrupts.
+func (r *IntrRates) TotalPct() float64 {
+	var t float64
+	for _, p := range r.Pct {
+		t += p
+	}
+	return t
+}
+
+// RatesSince computes i's interrupt activity over the interval
+// between prev and i. prev should be for the same CPU.
+func (i *IntrStats) RatesSince(prev *IntrStats) *IntrRates {
+	d := snapInterval(prev.Snaptime, i.Snaptime)
+	r := IntrRates{CPU: i.CPU, Interval: d}
+	for l := 1; l <= MaxPIL; l++ {
+		r.Rate[l] = perSecond(prev.Count[l], i.Count[l], 64, d)
+		r.Pct[l] = pct(float64(counterDelta(prev.Time[l], i.Time[l], 64)), float64(d))
+	}
+	return &r
+}
+
+// IntrRatesSince computes the interrupt activity of every CPU that is
+// present in both prev and cur, returning them sorted by CPU.
+func IntrRatesSince(prev, cur []*IntrStats) []*IntrRates {
+	pm := make(map[int]*IntrStats, len(prev))
+	for _, i := range prev {
+		pm[i.CPU] = i
+	}
+	var res []*IntrRates
+	for _, i := range cur {
+		if p, ok := pm[i.CPU]; ok {
+			res = append(res, i.RatesSince(p))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CPU < res[j].CPU })
+	return res
+}
+
+// WriteIntrstat writes rates to w in a form like intrstat, with a
+// line for each interrupt level that any CPU has had interrupts at
+// and a column of interrupts per second and percentage of time for
+// each CPU.
+func WriteIntrstat(w io.Writer, rates []*IntrRates) error {
+	var b strings.Builder
+	b.WriteString("      level |")
+	for _, r := range rates {
+		fmt.Fprintf(&b, " %9s %%tim", fmt.Sprintf("cpu%d", r.CPU))
+	}
+	b.WriteString("\n")
+	b.WriteString("------------+" + strings.Repeat("---------------", len(rates)) + "\n")
+	for l := 1; l <= MaxPIL; l++ {
+		active := false
+		for _, r := range rates {
+			active = active || r.Rate[l] > 0 || r.Pct[l] > 0
+		}
+		if !active {
+			continue
+		}
+		fmt.Fprintf(&b, "%11s |", fmt.Sprintf("level-%d", l))
+		for _, r := range rates {
+			fmt.Fprintf(&b, " %9.0f %4.1f", r.Rate[l], r.Pct[l])
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,69 @@
+//
+// Retrieving IntrStats.
+
+package kstat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// intrStats returns the interrupt statistics from a cpu:N:intrstat
+// kstat. k is refreshed.
+func (k *KStat) intrStats() (*IntrStats, error) {
+	if err := k.Refresh(); err != nil {
+		return nil, err
+	}
+	lst, err := k.AllNamed()
+	if err != nil {
+		return nil, err
+	}
+	is := IntrStats{CPU: k.Instance, Snaptime: k.Snaptime}
+	for _, n := range lst {
+		// Statistics are called level-<N>-count and
+		// level-<N>-time.
+		f := strings.Split(n.Name, "-")
+		if len(f) != 3 || f[0] != "level" {
+			continue
+		}
+		l, err := strconv.Atoi(f[1])
+		if err != nil || l < 1 || l > MaxPIL {
+			continue
+		}
+		if n.Type != Uint64 && n.Type != Uint32 {
+			return nil, fmt.Errorf("kstat %s statistic %s has wrong type %s", k, n.Name, n.Type)
+		}
+		switch f[2] {
+		case "count":
+			is.Count[l] = n.UintVal
+		case "time":
+			is.Time[l] = n.UintVal
+		}
+	}
+	return &is, nil
+}
+
+// IntrStats returns the current interrupt statistics of every CPU,
+// sorted by CPU. Each kstat is refreshed as it's read. CPUs whose
+// kstats have disappeared since the last Update() are skipped.
+func (t *Token) IntrStats() ([]*IntrStats, error) {
+	var res []*IntrStats
+	for _, k := range t.All() {
+		if k.Type != NamedStat || k.Module != "cpu" || k.Name != "intrstat" {
+			continue
+		}
+		is, err := k.intrStats()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, is)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CPU < res[j].CPU })
+	return res, nil
+}
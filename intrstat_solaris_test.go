@@ -0,0 +1,31 @@
+//
+// Test retrieving IntrStats.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+// Every CPU has an intrstat kstat, and every machine has had a clock
+// interrupt.
+func TestIntrStats(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	il, err := tok.IntrStats()
+	if err != nil {
+		t.Fatalf("IntrStats error: %s", err)
+	}
+	if len(il) == 0 {
+		t.Fatalf("no intrstat kstats found")
+	}
+	var total uint64
+	for _, is := range il {
+		for _, c := range is.Count {
+			total += c
+		}
+	}
+	if total == 0 {
+		t.Fatalf("no interrupts in intrstat kstats: %+v", il[0])
+	}
+}
@@ -0,0 +1,43 @@
+//
+// Test interrupt rates and the intrstat view.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestIntrRates(t *testing.T) {
+	sec := int64(time.Second)
+	p0 := &kstat.IntrStats{CPU: 0, Snaptime: sec}
+	p1 := &kstat.IntrStats{CPU: 1, Snaptime: sec}
+	c0 := &kstat.IntrStats{CPU: 0, Snaptime: 3 * sec}
+	c1 := &kstat.IntrStats{CPU: 1, Snaptime: 3 * sec}
+	c2 := &kstat.IntrStats{CPU: 2, Snaptime: 3 * sec}
+	c0.Count[5], c0.Time[5] = 2000, uint64(sec/10)
+	c1.Count[14], c1.Time[14] = 200, uint64(sec/100)
+
+	rl := kstat.IntrRatesSince([]*kstat.IntrStats{p1, p0}, []*kstat.IntrStats{c2, c1, c0})
+	if len(rl) != 2 || rl[0].CPU != 0 || rl[1].CPU != 1 {
+		t.Fatalf("wrong CPUs in rates: %+v", rl)
+	}
+	if r := rl[0]; r.Interval != 2*time.Second || r.Rate[5] != 1000 || !near(r.Pct[5], 5) || !near(r.TotalPct(), 5) {
+		t.Fatalf("bad cpu0 rates: %+v", r)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WriteIntrstat(&b, rl); err != nil {
+		t.Fatalf("WriteIntrstat error: %s", err)
+	}
+	exp := "      level |      cpu0 %tim      cpu1 %tim\n" +
+		"------------+------------------------------\n" +
+		"    level-5 |      1000  5.0         0  0.0\n" +
+		"   level-14 |         0  0.0       100  0.5\n"
+	if b.String() != exp {
+		t.Fatalf("WriteIntrstat output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
@@ -0,0 +1,91 @@
+//
+// Typed access to the kernel memory allocator's per-cache statistics
+// (the unix:0:<cache> kstats of class kmem_cache), plus a ::kmastat
+// style report of them.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// KmemCache is the statistics for a single kmem cache. Sizes are in
+// bytes; buffer and slab counts are current values, while allocation
+// and free counts are counters.
+type KmemCache struct {
+	Name     string `kstat:"name"`
+	Snaptime int64  `kstat:"snaptime"`
+
+	BufSize   uint64 `kstat:"buf_size"`
+	Align     uint64 `kstat:"align"`
+	ChunkSize uint64 `kstat:"chunk_size"`
+	SlabSize  uint64 `kstat:"slab_size"`
+
+	Alloc           uint64 `kstat:"alloc"`
+	AllocFail       uint64 `kstat:"alloc_fail"`
+	Free            uint64 `kstat:"free"`
+	DepotAlloc      uint64 `kstat:"depot_alloc"`
+	DepotFree       uint64 `kstat:"depot_free"`
+	DepotContention uint64 `kstat:"depot_contention"`
+	SlabAlloc       uint64 `kstat:"slab_alloc"`
+	SlabFree        uint64 `kstat:"slab_free"`
+
+	BufConstructed uint64 `kstat:"buf_constructed"`
+	BufAvail       uint64 `kstat:"buf_avail"`
+	BufInuse       uint64 `kstat:"buf_inuse"`
+	BufTotal       uint64 `kstat:"buf_total"`
+	BufMax         uint64 `kstat:"buf_max"`
+	SlabCreate     uint64 `kstat:"slab_create"`
+	SlabDestroy    uint64 `kstat:"slab_destroy"`
+	MemoryReserved uint64 `kstat:"memory_reserved"`
+
+	VmemSource     uint64 `kstat:"vmem_source"`
+	HashSize       uint64 `kstat:"hash_size"`
+	FullMagazines  uint64 `kstat:"full_magazines"`
+	EmptyMagazines uint64 `kstat:"empty_magazines"`
+	MagazineSize   uint64 `kstat:"magazine_size"`
+	Reap           uint64 `kstat:"reap"`
+}
+
+// MemInUse returns how much memory the cache is using, which is the
+// size of all of its slabs (as ::kmastat computes it).
+func (c *KmemCache) MemInUse() uint64 {
+	return (c.SlabCreate - c.SlabDestroy) * c.SlabSize
+}
+
+// WriteKmastat writes a ::kmastat style report of caches to w, sorted
+// by memory in use (largest first) and followed by the total memory
+// in use by all of them. If n is positive, only the n caches using
+// the most memory are listed, although the total covers all of them.
+func WriteKmastat(w io.Writer, caches []*KmemCache, n int) error {
+	cl := make([]*KmemCache, len(caches))
+	copy(cl, caches)
+	sort.SliceStable(cl, func(i, j int) bool { return cl[i].MemInUse() > cl[j].MemInUse() })
+
+	var b strings.Builder
+	var total, allocs, fails uint64
+	for _, c := range cl {
+		total += c.MemInUse()
+		allocs += c.Alloc
+		fails += c.AllocFail
+	}
+	if n > 0 && n < len(cl) {
+		cl = cl[:n]
+	}
+
+	sep := "------------------------- ------ ------ ------ ---------- --------- -----\n"
+	b.WriteString("cache                        buf    buf    buf     memory     alloc alloc\n")
+	b.WriteString("name                        size in use  total     in use   succeed  fail\n")
+	b.WriteString(sep)
+	for _, c := range cl {
+		fmt.Fprintf(&b, "%-25s %6d %6d %6d %10s %9d %5d\n", c.Name, c.BufSize,
+			c.BufInuse, c.BufTotal, niceNum(float64(c.MemInUse())), c.Alloc, c.AllocFail)
+	}
+	b.WriteString(sep)
+	fmt.Fprintf(&b, "%-46s %10s %9d %5d\n", "Total [kmem_caches]", niceNum(float64(total)), allocs, fails)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,34 @@
+//
+// Retrieving KmemCache statistics.
+
+package kstat
+
+import "syscall"
+
+// KmemCaches returns the current statistics for every kmem cache, in
+// no particular order. Each kstat is refreshed as it's read.
+//
+// Caches are created and destroyed as kernel modules are loaded and
+// unloaded, so you may want to call Update() first. Caches whose
+// kstats have disappeared since then are skipped.
+func (t *Token) KmemCaches() ([]*KmemCache, error) {
+	var res []*KmemCache
+	for _, k := range t.All() {
+		if k.Type != NamedStat || k.Module != "unix" || k.Class != "kmem_cache" {
+			continue
+		}
+		err := k.Refresh()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c := KmemCache{}
+		if err := k.fill(&c); err != nil {
+			return nil, err
+		}
+		res = append(res, &c)
+	}
+	return res, nil
+}
@@ -0,0 +1,25 @@
+//
+// Test retrieving KmemCache statistics.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+func TestKmemCaches(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	cl, err := tok.KmemCaches()
+	if err != nil {
+		t.Fatalf("KmemCaches error: %s", err)
+	}
+	if len(cl) == 0 {
+		t.Fatalf("no kmem caches found")
+	}
+	for _, c := range cl {
+		if c.Name == "" || c.BufSize == 0 {
+			t.Fatalf("kmem cache stats are odd: %+v", c)
+		}
+	}
+}
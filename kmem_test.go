@@ -0,0 +1,40 @@
+//
+// Test the kmastat report.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestWriteKmastat(t *testing.T) {
+	caches := []*kstat.KmemCache{
+		{Name: "kmem_alloc_8", BufSize: 8, BufInuse: 100, BufTotal: 500, SlabSize: 4096, SlabCreate: 3, SlabDestroy: 2, Alloc: 1000},
+		{Name: "zio_buf_131072", BufSize: 131072, BufInuse: 10, BufTotal: 16, SlabSize: 131072, SlabCreate: 20, SlabDestroy: 4, Alloc: 50, AllocFail: 1},
+		{Name: "streams_mblk", BufSize: 64, BufInuse: 1, BufTotal: 63, SlabSize: 4096, SlabCreate: 1},
+	}
+	if m := caches[1].MemInUse(); m != 16*131072 {
+		t.Fatalf("wrong MemInUse: %d", m)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WriteKmastat(&b, caches, 2); err != nil {
+		t.Fatalf("WriteKmastat error: %s", err)
+	}
+	exp := "cache                        buf    buf    buf     memory     alloc alloc\n" +
+		"name                        size in use  total     in use   succeed  fail\n" +
+		"------------------------- ------ ------ ------ ---------- --------- -----\n" +
+		"zio_buf_131072            131072     10     16      2.00M        50     1\n" +
+		"kmem_alloc_8                   8    100    500      4.00K      1000     0\n" +
+		"------------------------- ------ ------ ------ ---------- --------- -----\n" +
+		"Total [kmem_caches]                                 2.01M      1050     1\n"
+	if b.String() != exp {
+		t.Fatalf("WriteKmastat output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+	if caches[0].Name != "kmem_alloc_8" {
+		t.Fatalf("WriteKmastat reordered its argument")
+	}
+}
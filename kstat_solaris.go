@@ -20,6 +20,7 @@ package kstat
 //
 // #include <sys/types.h>
 // #include <stdlib.h>
+// #include <string.h>
 // #include <strings.h>
 // #include <kstat.h>
 //
@@ -73,6 +74,9 @@ type Token struct {
 	// we want to keep unique KStats. This holds some Go-level
 	// memory down, but I wave my hands.
 	ksm map[*C.struct_kstat]*KStat
+
+	// clock converts hrtimes to wall clock times; see Clock().
+	clock *Clock
 }
 
 // Open returns a kstat Token that is used to obtain kstats. It corresponds
@@ -114,6 +118,7 @@ func (t *Token) Close() error {
 	// KStat.ksp is pointing to by calling kstat_close().
 	for _, v := range t.ksm {
 		v.ksp = nil
+		v.clk = t.Clock()
 		v.tok = nil
 	}
 
@@ -198,6 +203,7 @@ func (t *Token) Update() (bool, error) {
 	// KStat's references to make them invalid.
 	for _, v := range t.ksm {
 		v.ksp = nil
+		v.clk = t.Clock()
 		v.tok = nil
 	}
 	// Make our new ksm map the current ksm map.
@@ -312,37 +318,6 @@ func (t *Token) GetNamed(module string, instance int, name, stat string) (*Named
 
 // -----
 
-// KSType is the type of the data in a KStat.
-type KSType int
-
-// The different types of data that a KStat may contain, ie these
-// are the value of a KStat.Type. We currently only support getting
-// Named and IO statistics.
-const (
-	RawStat   KSType = C.KSTAT_TYPE_RAW
-	NamedStat KSType = C.KSTAT_TYPE_NAMED
-	IntrStat  KSType = C.KSTAT_TYPE_INTR
-	IoStat    KSType = C.KSTAT_TYPE_IO
-	TimerStat KSType = C.KSTAT_TYPE_TIMER
-)
-
-func (tp KSType) String() string {
-	switch tp {
-	case RawStat:
-		return "raw"
-	case NamedStat:
-		return "named"
-	case IntrStat:
-		return "interrupt"
-	case IoStat:
-		return "io"
-	case TimerStat:
-		return "timer"
-	default:
-		return fmt.Sprintf("kstat_type:%d", tp)
-	}
-}
-
 // KStat is the access handle for the collection of statistics for a
 // particular module:instance:name kstat.
 //
@@ -370,6 +345,12 @@ type KStat struct {
 	ksp *C.struct_kstat
 	// We need access to the token to refresh the data
 	tok *Token
+	// clk is the token's Clock, kept when the KStat is
+	// invalidated so that its times stay consistent.
+	clk *Clock
+
+	// names interns the names of named statistics for ReadNamed.
+	names []string
 }
 
 // newKStat is our internal KStat constructor.
@@ -544,6 +525,109 @@ func (k *KStat) AllNamed() ([]*Named, error) {
 	return lst, nil
 }
 
+// ReadNamed is a lower overhead version of AllNamed for high
+// frequency collection. It reads all of the named statistics of a
+// named-type KStat into buf, reusing buf's storage if it's big
+// enough, and returns the resulting slice, which is in the same
+// order as AllNamed's result.
+//
+// Statistic names are interned on the KStat, integer statistics
+// need no conversion, and String and CharData values are only copied
+// if they differ from the StringVal already in the same position of
+// buf. Once buf has grown to size, reading a KStat whose string
+// values haven't changed does no allocation at all.
+//
+// As with AllNamed, ReadNamed does not refresh the KStat's data, so
+// normally you'll call Refresh() first. The Nameds in buf are
+// overwritten by each call that reuses it.
+func (k *KStat) ReadNamed(buf []Named) ([]Named, error) {
+	if err := k.setup(); err != nil {
+		return nil, err
+	}
+	n := int(k.ksp.ks_ndata)
+	if cap(buf) < n {
+		nb := make([]Named, n)
+		copy(nb, buf)
+		buf = nb
+	}
+	buf = buf[:n]
+	if len(k.names) != n {
+		k.names = make([]string, n)
+	}
+
+	for i := 0; i < n; i++ {
+		knp := C.get_nth_named(k.ksp, C.uint_t(i))
+		if knp == nil {
+			panic("get_nth_named returned surprise nil")
+		}
+		// Statistics can change names if a kstat's ks_update
+		// rearranges them, so we check that the interned
+		// name is still right.
+		cname := (*C.char)(unsafe.Pointer(&knp.name))
+		if !cstrEqual(cname, C.KSTAT_STRLEN, k.names[i]) {
+			k.names[i] = strndup(cname, C.KSTAT_STRLEN)
+		}
+
+		st := &buf[i]
+		// The old string value is only reusable if it's for
+		// the same statistic.
+		prev := ""
+		if st.Name == k.names[i] {
+			prev = st.StringVal
+		}
+		st.Name = k.names[i]
+		st.Type = NamedType(knp.data_type)
+		st.Snaptime = k.Snaptime
+		st.KStat = k
+		st.StringVal, st.IntVal, st.UintVal = "", 0, 0
+
+		switch st.Type {
+		case String:
+			if cs := C.get_named_char(knp); cs != nil {
+				l := int(C.strlen(cs))
+				if string(cbytes(cs, l)) == prev {
+					st.StringVal = prev
+				} else {
+					st.StringVal = C.GoStringN(cs, C.int(l))
+				}
+			}
+		case CharData:
+			cs := (*C.char)(unsafe.Pointer(&knp.value))
+			if cstrEqual(cs, 16, prev) {
+				st.StringVal = prev
+			} else {
+				st.StringVal = strndup(cs, 16)
+			}
+		case Int32, Int64:
+			st.IntVal = int64(C.get_named_int(knp))
+		case Uint32, Uint64:
+			st.UintVal = uint64(C.get_named_uint(knp))
+		default:
+			panic(fmt.Sprintf("unknown stat type: %d", st.Type))
+		}
+	}
+	return buf, nil
+}
+
+// cbytes returns the l bytes at cs as a byte slice, without copying.
+func cbytes(cs *C.char, l int) []byte {
+	return (*[1 << 30]byte)(unsafe.Pointer(cs))[:l:l]
+}
+
+// cstrEqual returns true if the C string in the max-byte field at cs,
+// which may not be null-terminated, is equal to s. It doesn't
+// allocate.
+func cstrEqual(cs *C.char, max int, s string) bool {
+	if len(s) > max {
+		return false
+	}
+	b := cbytes(cs, max)
+	if string(b[:len(s)]) != s {
+		return false
+	}
+	return len(s) == max || b[len(s)] == 0
+}
+
 // Named represents a particular kstat named statistic, ie the full
 //	module:instance:name:statistic
 // and its current value.
@@ -580,47 +664,24 @@ func (ks *Named) String() string {
 	return fmt.Sprintf("%s:%d:%s:%s", ks.KStat.Module, ks.KStat.Instance, ks.KStat.Name, ks.Name)
 }
 
-// NamedType represents the various types of named kstat statistics.
-type NamedType int
-
-// The different types of data that a named kstat statistic can be
-// (ie, these are the potential values of Named.Type).
-const (
-	CharData NamedType = C.KSTAT_DATA_CHAR
-	Int32    NamedType = C.KSTAT_DATA_INT32
-	Uint32   NamedType = C.KSTAT_DATA_UINT32
-	Int64    NamedType = C.KSTAT_DATA_INT64
-	Uint64   NamedType = C.KSTAT_DATA_UINT64
-	String   NamedType = C.KSTAT_DATA_STRING
-
-	// CharData is found in StringVal. At the moment we assume that
-	// it is a real string, because this matches how it seems to be
-	// used for short strings in the Solaris kernel. Someday we may
-	// find something that uses it as just a data dump for 16 bytes.
-
-	// Solaris sys/kstat.h also has _FLOAT (5) and _DOUBLE (6) types,
-	// but labels them as obsolete.
+// The KSType and NamedType constants are defined with fixed values in
+// types.go so that they exist on all platforms. These fail to compile
+// if any of them ever disagree with the C values.
+var (
+	_ = [1]struct{}{}[RawStat-C.KSTAT_TYPE_RAW]
+	_ = [1]struct{}{}[NamedStat-C.KSTAT_TYPE_NAMED]
+	_ = [1]struct{}{}[IntrStat-C.KSTAT_TYPE_INTR]
+	_ = [1]struct{}{}[IoStat-C.KSTAT_TYPE_IO]
+	_ = [1]struct{}{}[TimerStat-C.KSTAT_TYPE_TIMER]
+
+	_ = [1]struct{}{}[CharData-C.KSTAT_DATA_CHAR]
+	_ = [1]struct{}{}[Int32-C.KSTAT_DATA_INT32]
+	_ = [1]struct{}{}[Uint32-C.KSTAT_DATA_UINT32]
+	_ = [1]struct{}{}[Int64-C.KSTAT_DATA_INT64]
+	_ = [1]struct{}{}[Uint64-C.KSTAT_DATA_UINT64]
+	_ = [1]struct{}{}[String-C.KSTAT_DATA_STRING]
 )
 
-func (tp NamedType) String() string {
-	switch tp {
-	case CharData:
-		return "char"
-	case Int32:
-		return "int32"
-	case Uint32:
-		return "uint32"
-	case Int64:
-		return "int64"
-	case Uint64:
-		return "uint64"
-	case String:
-		return "string"
-	default:
-		return fmt.Sprintf("named_type-%d", tp)
-	}
-}
-
 // Create a new Stat from the kstat_named_t
 // We set the appropriate *Value field.
 func newNamed(k *KStat, knp *C.struct_kstat_named) *Named {
@@ -495,3 +495,94 @@ func TestUpdate(t *testing.T) {
 		t.Fatalf("%s valid after Close()", ks)
 	}
 }
+
+// Test that ReadNamed gives the same results as AllNamed, both into
+// an empty buffer and into a reused one, and that once the buffer is
+// big enough it doesn't allocate. cpu_info:0:cpu_info0 has string
+// and chardata statistics as well as integer ones.
+func TestReadNamed(t *testing.T) {
+	tok := start(t)
+	ks := lookup(t, tok, "cpu_info", "cpu_info0")
+	all, err := ks.AllNamed()
+	if err != nil {
+		t.Fatalf("%s AllNamed failed: %s", ks, err)
+	}
+	var buf []kstat.Named
+	for i := 0; i < 2; i++ {
+		buf, err = ks.ReadNamed(buf)
+		if err != nil {
+			t.Fatalf("%s ReadNamed failed: %s", ks, err)
+		}
+		if len(buf) != len(all) {
+			t.Fatalf("%s ReadNamed gave %d stats, AllNamed %d", ks, len(buf), len(all))
+		}
+		for j := range buf {
+			if buf[j] != *all[j] {
+				t.Fatalf("%s ReadNamed mismatch:\n%#v\n%#v", ks, buf[j], *all[j])
+			}
+		}
+	}
+
+	if n := testing.AllocsPerRun(10, func() { buf, _ = ks.ReadNamed(buf) }); n != 0 {
+		t.Fatalf("%s ReadNamed does %v allocations per run", ks, n)
+	}
+
+	// A buffer with the wrong statistics in it must not leak
+	// their string values through.
+	ks2 := lookup(t, tok, "cpu", "sys")
+	buf, err = ks2.ReadNamed(buf)
+	if err != nil {
+		t.Fatalf("%s ReadNamed failed: %s", ks2, err)
+	}
+	for _, n := range buf {
+		if n.KStat != ks2 || n.StringVal != "" {
+			t.Fatalf("%s ReadNamed bad stat: %#v", ks2, n)
+		}
+	}
+
+	stop(t, tok)
+	_, err = ks.ReadNamed(buf)
+	if err == nil {
+		t.Fatalf("ks.ReadNamed succeeds after Close")
+	}
+}
+
+// Compare the cost of AllNamed and ReadNamed for a typical
+// high-frequency collection loop of Refresh() and then read.
+func benchmarkRead(b *testing.B, read func(ks *kstat.KStat) error) {
+	tok, err := kstat.Open()
+	if err != nil {
+		b.Fatalf("Open failure: %s", err)
+	}
+	defer tok.Close()
+	ks, err := tok.Lookup("cpu", 0, "sys")
+	if err != nil {
+		b.Fatalf("lookup failure on cpu:0:sys: %s", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ks.Refresh(); err != nil {
+			b.Fatalf("%s Refresh failed: %s", ks, err)
+		}
+		if err := read(ks); err != nil {
+			b.Fatalf("%s read failed: %s", ks, err)
+		}
+	}
+}
+
+func BenchmarkAllNamed(b *testing.B) {
+	benchmarkRead(b, func(ks *kstat.KStat) error {
+		_, err := ks.AllNamed()
+		return err
+	})
+}
+
+func BenchmarkReadNamed(b *testing.B) {
+	var buf []kstat.Named
+	benchmarkRead(b, func(ks *kstat.KStat) error {
+		var err error
+		buf, err = ks.ReadNamed(buf)
+		return err
+	})
+}
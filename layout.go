@@ -0,0 +1,254 @@
+//
+// Pure Go decoding of the raw kstat structures from byte slices, so
+// that raw data captured on one platform can be decoded on another.
+
+package kstat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// Layout describes how the raw kstat structures that we know about
+// are laid out on a particular platform. None of kstat_io_t,
+// sysinfo_t, vminfo_t, struct var, or struct mntinfo_kstat contain
+// pointers or longs, and their 64-bit fields are all naturally
+// aligned, so all of the platforms that illumos and Solaris run on
+// use the same field offsets and sizes (the Sizeof* constants). What
+// differs is the byte order, since SPARC is big-endian.
+type Layout struct {
+	// Arch is the name of the platform, eg "amd64" or "sparcv9".
+	Arch string
+	// Order is the platform's byte order.
+	Order binary.ByteOrder
+}
+
+// The layouts of the platforms that we know about.
+var (
+	LayoutAmd64   = &Layout{"amd64", binary.LittleEndian}
+	LayoutArm64   = &Layout{"arm64", binary.LittleEndian}
+	LayoutSparcv9 = &Layout{"sparcv9", binary.BigEndian}
+)
+
+// Layouts maps platform names (as used in Layout.Arch) to their
+// Layout.
+var Layouts = map[string]*Layout{
+	"amd64":   LayoutAmd64,
+	"arm64":   LayoutArm64,
+	"sparcv9": LayoutSparcv9,
+}
+
+// NativeLayout is the Layout of the platform we're running on.
+var NativeLayout = nativeLayout()
+
+func nativeLayout() *Layout {
+	if l, ok := Layouts[runtime.GOARCH]; ok {
+		return l
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		order = binary.LittleEndian
+	}
+	return &Layout{runtime.GOARCH, order}
+}
+
+// The sizes of the raw kstat structures, which are the same on all
+// platforms.
+const (
+	SizeofIO      = 0x50
+	SizeofSysinfo = 0x18
+	SizeofVminfo  = 0x30
+	SizeofVar     = 0x3c
+	SizeofMntinfo = 0x1ec
+	SizeofMemUnit = 0x10
+)
+
+// rawReader reads successive fields out of a raw structure.
+type rawReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func (r *rawReader) u32() uint32 {
+	v := r.order.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *rawReader) u64() uint64 {
+	v := r.order.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *rawReader) i32() int32 { return int32(r.u32()) }
+func (r *rawReader) i64() int64 { return int64(r.u64()) }
+
+func (r *rawReader) chars(dst []int8) {
+	for i := range dst {
+		dst[i] = int8(r.b[i])
+	}
+	r.b = r.b[len(dst):]
+}
+
+func newRawReader(b []byte, order binary.ByteOrder, size int, what string) (*rawReader, error) {
+	if len(b) != size {
+		return nil, fmt.Errorf("%s data is wrong size %d (should be %d)", what, len(b), size)
+	}
+	return &rawReader{b, order}, nil
+}
+
+// DecodeIO decodes a kstat_io_t in the given byte order.
+func DecodeIO(b []byte, order binary.ByteOrder) (*IO, error) {
+	r, err := newRawReader(b, order, SizeofIO, "kstat_io_t")
+	if err != nil {
+		return nil, err
+	}
+	io := IO{}
+	io.Nread = r.u64()
+	io.Nwritten = r.u64()
+	io.Reads = r.u32()
+	io.Writes = r.u32()
+	io.Wtime = r.i64()
+	io.Wlentime = r.i64()
+	io.Wlastupdate = r.i64()
+	io.Rtime = r.i64()
+	io.Rlentime = r.i64()
+	io.Rlastupdate = r.i64()
+	io.Wcnt = r.u32()
+	io.Rcnt = r.u32()
+	return &io, nil
+}
+
+// DecodeSysinfo decodes a sysinfo_t in the given byte order.
+func DecodeSysinfo(b []byte, order binary.ByteOrder) (*Sysinfo, error) {
+	r, err := newRawReader(b, order, SizeofSysinfo, "sysinfo_t")
+	if err != nil {
+		return nil, err
+	}
+	si := Sysinfo{}
+	si.Updates = r.u32()
+	si.Runque = r.u32()
+	si.Runocc = r.u32()
+	si.Swpque = r.u32()
+	si.Swpocc = r.u32()
+	si.Waiting = r.u32()
+	return &si, nil
+}
+
+// DecodeVminfo decodes a vminfo_t in the given byte order.
+func DecodeVminfo(b []byte, order binary.ByteOrder) (*Vminfo, error) {
+	r, err := newRawReader(b, order, SizeofVminfo, "vminfo_t")
+	if err != nil {
+		return nil, err
+	}
+	vi := Vminfo{}
+	vi.Freemem = r.u64()
+	vi.Resv = r.u64()
+	vi.Alloc = r.u64()
+	vi.Avail = r.u64()
+	vi.Free = r.u64()
+	vi.Updates = r.u64()
+	return &vi, nil
+}
+
+// DecodeVar decodes a struct var in the given byte order.
+func DecodeVar(b []byte, order binary.ByteOrder) (*Var, error) {
+	r, err := newRawReader(b, order, SizeofVar, "struct var")
+	if err != nil {
+		return nil, err
+	}
+	v := Var{}
+	v.Buf = r.i32()
+	v.Call = r.i32()
+	v.Proc = r.i32()
+	v.Maxupttl = r.i32()
+	v.Nglobpris = r.i32()
+	v.Maxsyspri = r.i32()
+	v.Clist = r.i32()
+	v.Maxup = r.i32()
+	v.Hbuf = r.i32()
+	v.Hmask = r.i32()
+	v.Pbuf = r.i32()
+	v.Sptmap = r.i32()
+	v.Maxpmem = r.i32()
+	v.Autoup = r.i32()
+	v.Bufhwm = r.i32()
+	return &v, nil
+}
+
+// DecodeMntinfo decodes a struct mntinfo_kstat in the given byte
+// order.
+func DecodeMntinfo(b []byte, order binary.ByteOrder) (*Mntinfo, error) {
+	r, err := newRawReader(b, order, SizeofMntinfo, "struct mntinfo_kstat")
+	if err != nil {
+		return nil, err
+	}
+	mi := Mntinfo{}
+	r.chars(mi.RProto[:])
+	mi.Vers = r.u32()
+	mi.Flags = r.u32()
+	mi.Secmod = r.u32()
+	mi.Curread = r.u32()
+	mi.Curwrite = r.u32()
+	mi.Timeo = r.i32()
+	mi.Retrans = r.i32()
+	mi.Acregmin = r.u32()
+	mi.Acregmax = r.u32()
+	mi.Acdirmin = r.u32()
+	mi.Acdirmax = r.u32()
+	for i := range mi.Timers {
+		mi.Timers[i].Srtt = r.u32()
+		mi.Timers[i].Deviate = r.u32()
+		mi.Timers[i].Rtxcur = r.u32()
+	}
+	mi.Noresponse = r.u32()
+	mi.Failover = r.u32()
+	mi.Remap = r.u32()
+	r.chars(mi.RCurserver[:])
+	// The remaining three bytes are trailing padding.
+	return &mi, nil
+}
+
+// DecodeMemUnits decodes an array of 'struct memunit' records in the
+// given byte order. The data must be a whole number of records.
+func DecodeMemUnits(b []byte, order binary.ByteOrder) ([]MemUnit, error) {
+	if len(b)%SizeofMemUnit != 0 {
+		return nil, fmt.Errorf("struct memunit data size %d is not a multiple of %d", len(b), SizeofMemUnit)
+	}
+	r := &rawReader{b, order}
+	mu := make([]MemUnit, len(b)/SizeofMemUnit)
+	for i := range mu {
+		mu[i].Address = r.u64()
+		mu[i].Size = r.u64()
+	}
+	return mu, nil
+}
+
+// Decode decodes the raw data of one of the raw kstats that the
+// package knows about, module:*:name, as captured on the Layout's
+// platform. The result is the same as KStat.Decode() would give
+// for the kstat on that platform with the package's own decoders.
+//
+// IO statistics aren't raw kstats and they're named after whatever
+// device or thing they're for, so Decode doesn't know about them;
+// use DecodeIO() directly on their data.
+func (l *Layout) Decode(module, name string, data []byte) (interface{}, error) {
+	switch {
+	case module == "mm" && name == "phys_installed", module == "unix" && name == "page_retire_list":
+		return DecodeMemUnits(data, l.Order)
+	case module == "unix" && name == "sysinfo":
+		return DecodeSysinfo(data, l.Order)
+	case module == "unix" && name == "vminfo":
+		return DecodeVminfo(data, l.Order)
+	case module == "unix" && name == "var":
+		return DecodeVar(data, l.Order)
+	case module == "nfs" && name == "mntinfo":
+		return DecodeMntinfo(data, l.Order)
+	default:
+		return nil, fmt.Errorf("%s: no decoder for %s:%s", l.Arch, module, name)
+	}
+}
@@ -0,0 +1,101 @@
+//
+// Test the pure Go decoders for raw kstat structures. None of this
+// needs a kernel.
+
+package kstat_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unsafe"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// The Sizeof constants should match our actual Go structs.
+func TestLayoutSizes(t *testing.T) {
+	if unsafe.Sizeof(kstat.IO{}) != kstat.SizeofIO ||
+		unsafe.Sizeof(kstat.Sysinfo{}) != kstat.SizeofSysinfo ||
+		unsafe.Sizeof(kstat.Vminfo{}) != kstat.SizeofVminfo ||
+		unsafe.Sizeof(kstat.Var{}) != kstat.SizeofVar ||
+		unsafe.Sizeof(kstat.Mntinfo{}) != kstat.SizeofMntinfo ||
+		unsafe.Sizeof(kstat.MemUnit{}) != kstat.SizeofMemUnit {
+		t.Fatalf("Sizeof constants do not match struct sizes")
+	}
+}
+
+// encode turns v into bytes the way a platform with the given byte
+// order would lay it out.
+func encode(t *testing.T, order binary.ByteOrder, v interface{}) []byte {
+	var b bytes.Buffer
+	if err := binary.Write(&b, order, v); err != nil {
+		t.Fatalf("binary.Write of %T: %s", v, err)
+	}
+	return b.Bytes()
+}
+
+// Test that we can decode data from both big and little endian
+// platforms.
+func TestLayoutDecode(t *testing.T) {
+	io := kstat.IO{Nread: 1 << 40, Nwritten: 12345, Reads: 10, Writes: 20,
+		Wtime: 1, Wlentime: 2, Wlastupdate: 3, Rtime: 4, Rlentime: 5,
+		Rlastupdate: -6, Wcnt: 7, Rcnt: 8}
+	si := kstat.Sysinfo{Updates: 100, Runque: 1, Runocc: 2, Swpque: 3, Swpocc: 4, Waiting: 5}
+	vi := kstat.Vminfo{Freemem: 1 << 33, Resv: 1, Alloc: 2, Avail: 3, Free: 4, Updates: 5}
+	mu := []kstat.MemUnit{{Address: 0x1000, Size: 1 << 32}, {Address: 1 << 40, Size: 0x2000}}
+	vr := kstat.Var{Buf: 1, Call: 2, Proc: 30000, Maxup: 29995, Autoup: 30, Bufhwm: -1}
+	mi := testMntinfo()
+
+	for _, l := range []*kstat.Layout{kstat.LayoutAmd64, kstat.LayoutSparcv9} {
+		io2, err := kstat.DecodeIO(encode(t, l.Order, io), l.Order)
+		if err != nil || *io2 != io {
+			t.Fatalf("%s: IO decode failed: %v: %+v", l.Arch, err, io2)
+		}
+		r, err := l.Decode("unix", "sysinfo", encode(t, l.Order, si))
+		if si2, ok := r.(*kstat.Sysinfo); err != nil || !ok || *si2 != si {
+			t.Fatalf("%s: Sysinfo decode failed: %v: %+v", l.Arch, err, r)
+		}
+		r, err = l.Decode("unix", "vminfo", encode(t, l.Order, vi))
+		if vi2, ok := r.(*kstat.Vminfo); err != nil || !ok || *vi2 != vi {
+			t.Fatalf("%s: Vminfo decode failed: %v: %+v", l.Arch, err, r)
+		}
+		r, err = l.Decode("unix", "var", encode(t, l.Order, vr))
+		if vr2, ok := r.(*kstat.Var); err != nil || !ok || *vr2 != vr {
+			t.Fatalf("%s: Var decode failed: %v: %+v", l.Arch, err, r)
+		}
+		r, err = l.Decode("nfs", "mntinfo", encode(t, l.Order, mi))
+		mi2, ok := r.(*kstat.Mntinfo)
+		if err != nil || !ok || mi2.Proto() != "tcp" || mi2.Curserver() != "fileserver" || mi2.Timers != mi.Timers || mi2.Flags != mi.Flags {
+			t.Fatalf("%s: Mntinfo decode failed: %v: %+v", l.Arch, err, r)
+		}
+		r, err = l.Decode("mm", "phys_installed", encode(t, l.Order, mu))
+		if mu2, ok := r.([]kstat.MemUnit); err != nil || !ok || len(mu2) != len(mu) || mu2[1] != mu[1] {
+			t.Fatalf("%s: MemUnit decode failed: %v: %+v", l.Arch, err, r)
+		}
+	}
+
+	// Little-endian data is not big-endian data.
+	si2, err := kstat.DecodeSysinfo(encode(t, binary.LittleEndian, si), binary.BigEndian)
+	if err != nil || *si2 == si {
+		t.Fatalf("byte order made no difference: %v: %+v", err, si2)
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	_, err := kstat.DecodeIO(make([]byte, kstat.SizeofIO-1), binary.LittleEndian)
+	if err == nil {
+		t.Fatalf("DecodeIO of short data succeeded")
+	}
+	_, err = kstat.LayoutArm64.Decode("unix", "nosuch", make([]byte, 16))
+	if err == nil {
+		t.Fatalf("Decode of unknown raw kstat succeeded")
+	}
+	_, err = kstat.LayoutSparcv9.Decode("unix", "page_retire_list", make([]byte, kstat.SizeofMemUnit+1))
+	if err == nil {
+		t.Fatalf("Decode of partial MemUnit records succeeded")
+	}
+	if kstat.Layouts["sparcv9"].Order != binary.BigEndian {
+		t.Fatalf("sparcv9 is not big-endian")
+	}
+}
@@ -0,0 +1,169 @@
+//
+// Typed access to datalink statistics from link:*:* kstats, plus
+// dlstat-style rates.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// LinkState is the state of a datalink, from the link_state
+// statistic.
+type LinkState int32
+
+// The link states (LINK_STATE_* in sys/mac.h).
+const (
+	LinkStateUnknown LinkState = -1
+	LinkStateDown    LinkState = 0
+	LinkStateUp      LinkState = 1
+)
+
+func (s LinkState) String() string {
+	switch s {
+	case LinkStateUnknown:
+		return "unknown"
+	case LinkStateDown:
+		return "down"
+	case LinkStateUp:
+		return "up"
+	default:
+		return fmt.Sprintf("link_state-%d", int32(s))
+	}
+}
+
+// LinkDuplex is the duplex of a datalink, from the link_duplex
+// statistic.
+type LinkDuplex uint32
+
+// The link duplexes (LINK_DUPLEX_* in sys/mac.h).
+const (
+	LinkDuplexUnknown LinkDuplex = 0
+	LinkDuplexHalf    LinkDuplex = 1
+	LinkDuplexFull    LinkDuplex = 2
+)
+
+func (d LinkDuplex) String() string {
+	switch d {
+	case LinkDuplexUnknown:
+		return "unknown"
+	case LinkDuplexHalf:
+		return "half"
+	case LinkDuplexFull:
+		return "full"
+	default:
+		return fmt.Sprintf("link_duplex-%d", uint32(d))
+	}
+}
+
+// LinkStats is the statistics for one datalink (a physical NIC, a
+// VNIC, an aggregation, an etherstub, and so on) from its link:*:<link>
+// named kstat. The kstat instance is the zone ID of the zone that the
+// link is in.
+type LinkStats struct {
+	Link     string `kstat:"name"`
+	Zoneid   int    `kstat:"instance"`
+	Snaptime int64  `kstat:"snaptime"`
+	Zonename string `kstat:"zonename"`
+
+	RBytes     uint64 `kstat:"rbytes64"`
+	OBytes     uint64 `kstat:"obytes64"`
+	IPackets   uint64 `kstat:"ipackets64"`
+	OPackets   uint64 `kstat:"opackets64"`
+	IErrors    uint64 `kstat:"ierrors,uint32"`
+	OErrors    uint64 `kstat:"oerrors,uint32"`
+	MultiRcv   uint64 `kstat:"multircv,uint32"`
+	MultiXmt   uint64 `kstat:"multixmt,uint32"`
+	BrdcstRcv  uint64 `kstat:"brdcstrcv,uint32"`
+	BrdcstXmt  uint64 `kstat:"brdcstxmt,uint32"`
+	NoRcvBuf   uint64 `kstat:"norcvbuf,uint32"`
+	NoXmtBuf   uint64 `kstat:"noxmtbuf,uint32"`
+	Collisions uint64 `kstat:"collisions,uint32"`
+
+	// IfSpeed is the link speed in bits per second and LinkSpeed
+	// is it in Mbits per second. Not every link has a link_speed
+	// statistic, so LinkSpeed may be derived from IfSpeed or come
+	// from the mac kstat of the underlying device.
+	IfSpeed    uint64     `kstat:"ifspeed,gauge"`
+	LinkSpeed  uint64     `kstat:"link_speed,gauge"`
+	LinkState  LinkState  `kstat:"link_state,state"`
+	LinkDuplex LinkDuplex `kstat:"link_duplex,state"`
+
+	// Physical is true if the link has a <driver>:<instance>:mac
+	// kstat, which means that it's a physical device.
+	Physical bool
+}
+
+// LinkRates is the per-second rates of a datalink's counters over an
+// interval.
+type LinkRates struct {
+	Link     string
+	Interval time.Duration
+
+	RBytes     float64
+	OBytes     float64
+	IPackets   float64
+	OPackets   float64
+	IErrors    float64
+	OErrors    float64
+	Collisions float64
+}
+
+// RatesSince computes the link's rates over the interval between prev
+// and l. prev should be for the same link.
+func (l *LinkStats) RatesSince(prev *LinkStats) *LinkRates {
+	d := snapInterval(prev.Snaptime, l.Snaptime)
+	return &LinkRates{
+		Link:       l.Link,
+		Interval:   d,
+		RBytes:     perSecond(prev.RBytes, l.RBytes, 64, d),
+		OBytes:     perSecond(prev.OBytes, l.OBytes, 64, d),
+		IPackets:   perSecond(prev.IPackets, l.IPackets, 64, d),
+		OPackets:   perSecond(prev.OPackets, l.OPackets, 64, d),
+		IErrors:    perSecond(prev.IErrors, l.IErrors, 32, d),
+		OErrors:    perSecond(prev.OErrors, l.OErrors, 32, d),
+		Collisions: perSecond(prev.Collisions, l.Collisions, 32, d),
+	}
+}
+
+// LinkRatesSince computes the rates of every link present in both
+// prev and cur (matched by zone and name), sorted by link name.
+func LinkRatesSince(prev, cur []*LinkStats) []*LinkRates {
+	key := func(l *LinkStats) string { return fmt.Sprintf("%d:%s", l.Zoneid, l.Link) }
+	pm := make(map[string]*LinkStats, len(prev))
+	for _, l := range prev {
+		pm[key(l)] = l
+	}
+	var res []*LinkRates
+	for _, l := range cur {
+		if p, ok := pm[key(l)]; ok {
+			res = append(res, l.RatesSince(p))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Link < res[j].Link })
+	return res
+}
+
+// WriteLinkRates writes rates to w as a table in the style of
+// dlstat(1M), with one line per link and numbers abbreviated with
+// K/M/G suffixes.
+func WriteLinkRates(w io.Writer, rates []*LinkRates) error {
+	_, err := fmt.Fprintf(w, "%16s %8s %8s %8s %8s %8s %8s %8s\n",
+		"LINK", "IPKTS", "RBYTES", "IERRS", "OPKTS", "OBYTES", "OERRS", "COLLS")
+	if err != nil {
+		return err
+	}
+	for _, r := range rates {
+		_, err = fmt.Fprintf(w, "%16s %8s %8s %8s %8s %8s %8s %8s\n", r.Link,
+			niceNum(r.IPackets), niceNum(r.RBytes), niceNum(r.IErrors),
+			niceNum(r.OPackets), niceNum(r.OBytes), niceNum(r.OErrors),
+			niceNum(r.Collisions))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
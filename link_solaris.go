@@ -0,0 +1,110 @@
+//
+// Retrieving LinkStats.
+
+package kstat
+
+import (
+	"strconv"
+	"syscall"
+)
+
+// splitDev splits a device-style link name like 'e1000g0' into its
+// driver and instance number. ok is false if the name doesn't end in
+// digits or is all digits.
+func splitDev(name string) (drv string, inst int, ok bool) {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	if i == 0 || i == len(name) {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(name[i:])
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:i], n, true
+}
+
+// linkStats fills in a LinkStats from the link kstat k, using macs to
+// find the device's mac kstat if it has one.
+func linkStats(k *KStat, macs map[string]*KStat) (*LinkStats, error) {
+	if err := k.Refresh(); err != nil {
+		return nil, err
+	}
+	l := LinkStats{}
+	if err := k.fill(&l); err != nil {
+		return nil, err
+	}
+	if drv, inst, ok := splitDev(l.Link); ok {
+		if mk, ok := macs[drv+":"+strconv.Itoa(inst)]; ok {
+			l.Physical = true
+			var m struct {
+				LinkSpeed uint64 `kstat:"link_speed"`
+			}
+			// If the mac kstat has gone away, we just don't
+			// get its link speed.
+			err := mk.Refresh()
+			if err != nil && err != syscall.ENXIO {
+				return nil, err
+			}
+			if err == nil {
+				if err := mk.fill(&m); err != nil {
+					return nil, err
+				}
+			}
+			if l.LinkSpeed == 0 {
+				l.LinkSpeed = m.LinkSpeed
+			}
+		}
+	}
+	if l.LinkSpeed == 0 {
+		l.LinkSpeed = l.IfSpeed / 1000000
+	}
+	return &l, nil
+}
+
+// macKStats returns a map of all <driver>:<instance>:mac kstats, keyed
+// by driver:instance.
+func (t *Token) macKStats() map[string]*KStat {
+	macs := make(map[string]*KStat)
+	for _, k := range t.All() {
+		if k.Name == "mac" && k.Type == NamedStat {
+			macs[k.Module+":"+strconv.Itoa(k.Instance)] = k
+		}
+	}
+	return macs
+}
+
+// Links returns current statistics for every datalink with a
+// link:*:* kstat, in no particular order. Each kstat is refreshed as
+// it is read. Links whose kstats have disappeared since the last
+// Update() (for example because they were unplumbed) are skipped.
+func (t *Token) Links() ([]*LinkStats, error) {
+	macs := t.macKStats()
+	var res []*LinkStats
+	for _, k := range t.All() {
+		if k.Module != "link" || k.Type != NamedStat {
+			continue
+		}
+		l, err := linkStats(k, macs)
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, nil
+}
+
+// Link returns current statistics for a single datalink, from the
+// first link:*:<name> kstat.
+func (t *Token) Link(name string) (*LinkStats, error) {
+	k, err := t.Lookup("link", -1, name)
+	if err != nil {
+		return nil, err
+	}
+	return linkStats(k, t.macKStats())
+}
@@ -0,0 +1,34 @@
+//
+// Test retrieving LinkStats.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+// Every machine should have at least one datalink, although it may
+// not be up.
+func TestLinks(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	ll, err := tok.Links()
+	if err != nil {
+		t.Fatalf("Links error: %s", err)
+	}
+	if len(ll) == 0 {
+		t.Skip("skipping test due to no link kstats")
+	}
+	for _, l := range ll {
+		if l.Link == "" || l.Snaptime == 0 {
+			t.Fatalf("link stats are odd: %+v", l)
+		}
+	}
+	l, err := tok.Link(ll[0].Link)
+	if err != nil {
+		t.Fatalf("Link(%q) error: %s", ll[0].Link, err)
+	}
+	if l.Link != ll[0].Link || l.RBytes < ll[0].RBytes {
+		t.Fatalf("Link(%q) is inconsistent with Links: %+v vs %+v", l.Link, l, ll[0])
+	}
+}
@@ -0,0 +1,52 @@
+//
+// Test link rate calculations and formatting.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestLinkRates(t *testing.T) {
+	sec := int64(time.Second)
+	prev := []*kstat.LinkStats{
+		{Link: "net0", Snaptime: sec, RBytes: 1000, IPackets: 10, OBytes: 0},
+		{Link: "vnic0", Zoneid: 3, Snaptime: sec},
+	}
+	cur := []*kstat.LinkStats{
+		{Link: "vnic0", Zoneid: 3, Snaptime: 3 * sec, OPackets: 4000, OBytes: 3 << 20},
+		{Link: "net0", Snaptime: 3 * sec, RBytes: 1000 + 2*1500, IPackets: 12, IErrors: 2},
+		{Link: "net0", Zoneid: 1, Snaptime: 3 * sec},
+	}
+	rl := kstat.LinkRatesSince(prev, cur)
+	if len(rl) != 2 || rl[0].Link != "net0" || rl[1].Link != "vnic0" {
+		t.Fatalf("wrong links in rates: %+v", rl)
+	}
+	if r := rl[0]; r.Interval != 2*time.Second || r.RBytes != 1500 || r.IPackets != 1 || r.IErrors != 1 {
+		t.Fatalf("bad net0 rates: %+v", r)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WriteLinkRates(&b, rl); err != nil {
+		t.Fatalf("WriteLinkRates error: %s", err)
+	}
+	exp := "            LINK    IPKTS   RBYTES    IERRS    OPKTS   OBYTES    OERRS    COLLS\n" +
+		"            net0        1    1.46K        1        0        0        0        0\n" +
+		"           vnic0        0        0        0    1.95K    1.50M        0        0\n"
+	if b.String() != exp {
+		t.Fatalf("WriteLinkRates output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
+
+func TestLinkStateNames(t *testing.T) {
+	if kstat.LinkStateUp.String() != "up" || kstat.LinkState(-1).String() != "unknown" {
+		t.Fatalf("bad link state names")
+	}
+	if kstat.LinkDuplexFull.String() != "full" || kstat.LinkDuplex(7).String() != "link_duplex-7" {
+		t.Fatalf("bad link duplex names")
+	}
+}
@@ -0,0 +1,228 @@
+//
+// Typed access to the MIB-II counters in the tcp:0:tcp, udp:0:udp,
+// ip:0:icmp, and ip:0:ip named kstats, plus a netstat -s style report
+// of them. Per-second rates can be computed with StatRates().
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// TCPStats is the TCP MIB-II statistics from tcp:0:tcp. Fields
+// tagged as gauges are current values or settings; everything else
+// is a counter. Counters tagged uint32 are 32-bit kstats that wrap
+// around, unlike the 64-bit "high capacity" ones.
+type TCPStats struct {
+	Snaptime int64 `kstat:"snaptime"`
+
+	RtoAlgorithm  int64  `kstat:"rtoAlgorithm,gauge"`
+	RtoMin        int64  `kstat:"rtoMin,gauge"`
+	RtoMax        int64  `kstat:"rtoMax,gauge"`
+	MaxConn       int64  `kstat:"maxConn,gauge"`
+	ActiveOpens   uint64 `kstat:"activeOpens,uint32"`
+	PassiveOpens  uint64 `kstat:"passiveOpens,uint32"`
+	AttemptFails  uint64 `kstat:"attemptFails,uint32"`
+	EstabResets   uint64 `kstat:"estabResets,uint32"`
+	CurrEstab     uint64 `kstat:"currEstab,gauge"`
+	InSegs        uint64 `kstat:"inSegs"`
+	OutSegs       uint64 `kstat:"outSegs"`
+	RetransSegs   uint64 `kstat:"retransSegs,uint32"`
+	InErrs        uint64 `kstat:"inErrs,uint32"`
+	OutRsts       uint64 `kstat:"outRsts,uint32"`
+	ConnTableSize uint64 `kstat:"connTableSize,gauge"`
+
+	OutDataSegs        uint64 `kstat:"outDataSegs,uint32"`
+	OutDataBytes       uint64 `kstat:"outDataBytes,uint32"`
+	RetransBytes       uint64 `kstat:"retransBytes,uint32"`
+	OutAck             uint64 `kstat:"outAck,uint32"`
+	OutAckDelayed      uint64 `kstat:"outAckDelayed,uint32"`
+	OutUrg             uint64 `kstat:"outUrg,uint32"`
+	OutWinUpdate       uint64 `kstat:"outWinUpdate,uint32"`
+	OutWinProbe        uint64 `kstat:"outWinProbe,uint32"`
+	OutControl         uint64 `kstat:"outControl,uint32"`
+	OutFastRetrans     uint64 `kstat:"outFastRetrans,uint32"`
+	InAckSegs          uint64 `kstat:"inAckSegs,uint32"`
+	InAckBytes         uint64 `kstat:"inAckBytes,uint32"`
+	InDupAck           uint64 `kstat:"inDupAck,uint32"`
+	InAckUnsent        uint64 `kstat:"inAckUnsent,uint32"`
+	InDataInorderSegs  uint64 `kstat:"inDataInorderSegs,uint32"`
+	InDataInorderBytes uint64 `kstat:"inDataInorderBytes,uint32"`
+	InDataUnorderSegs  uint64 `kstat:"inDataUnorderSegs,uint32"`
+	InDataUnorderBytes uint64 `kstat:"inDataUnorderBytes,uint32"`
+	InDataDupSegs      uint64 `kstat:"inDataDupSegs,uint32"`
+	InDataDupBytes     uint64 `kstat:"inDataDupBytes,uint32"`
+	InDataPartDupSegs  uint64 `kstat:"inDataPartDupSegs,uint32"`
+	InDataPartDupBytes uint64 `kstat:"inDataPartDupBytes,uint32"`
+	InDataPastWinSegs  uint64 `kstat:"inDataPastWinSegs,uint32"`
+	InDataPastWinBytes uint64 `kstat:"inDataPastWinBytes,uint32"`
+	InWinProbe         uint64 `kstat:"inWinProbe,uint32"`
+	InWinUpdate        uint64 `kstat:"inWinUpdate,uint32"`
+	InClosed           uint64 `kstat:"inClosed,uint32"`
+	RttNoUpdate        uint64 `kstat:"rttNoUpdate,uint32"`
+	RttUpdate          uint64 `kstat:"rttUpdate,uint32"`
+	TimRetrans         uint64 `kstat:"timRetrans,uint32"`
+	TimRetransDrop     uint64 `kstat:"timRetransDrop,uint32"`
+	TimKeepalive       uint64 `kstat:"timKeepalive,uint32"`
+	TimKeepaliveProbe  uint64 `kstat:"timKeepaliveProbe,uint32"`
+	TimKeepaliveDrop   uint64 `kstat:"timKeepaliveDrop,uint32"`
+	ListenDrop         uint64 `kstat:"listenDrop,uint32"`
+	ListenDropQ0       uint64 `kstat:"listenDropQ0,uint32"`
+	HalfOpenDrop       uint64 `kstat:"halfOpenDrop,uint32"`
+	OutSackRetransSegs uint64 `kstat:"outSackRetransSegs,uint32"`
+}
+
+// UDPStats is the UDP MIB-II statistics from udp:0:udp.
+type UDPStats struct {
+	Snaptime int64 `kstat:"snaptime"`
+
+	InDatagrams  uint64 `kstat:"inDatagrams"`
+	InErrors     uint64 `kstat:"inErrors,uint32"`
+	OutDatagrams uint64 `kstat:"outDatagrams"`
+	OutErrors    uint64 `kstat:"outErrors,uint32"`
+	EntrySize    uint64 `kstat:"entrySize,gauge"`
+	Entry6Size   uint64 `kstat:"entry6Size,gauge"`
+}
+
+// ICMPStats is the ICMP MIB-II statistics from ip:0:icmp. They are
+// all counters.
+type ICMPStats struct {
+	Snaptime int64 `kstat:"snaptime"`
+
+	InMsgs           uint64 `kstat:"inMsgs,uint32"`
+	InErrors         uint64 `kstat:"inErrors,uint32"`
+	InCksumErrs      uint64 `kstat:"inCksumErrs,uint32"`
+	InUnknowns       uint64 `kstat:"inUnknowns,uint32"`
+	InDestUnreachs   uint64 `kstat:"inDestUnreachs,uint32"`
+	InTimeExcds      uint64 `kstat:"inTimeExcds,uint32"`
+	InParmProbs      uint64 `kstat:"inParmProbs,uint32"`
+	InSrcQuenchs     uint64 `kstat:"inSrcQuenchs,uint32"`
+	InRedirects      uint64 `kstat:"inRedirects,uint32"`
+	InBadRedirects   uint64 `kstat:"inBadRedirects,uint32"`
+	InEchos          uint64 `kstat:"inEchos,uint32"`
+	InEchoReps       uint64 `kstat:"inEchoReps,uint32"`
+	InTimestamps     uint64 `kstat:"inTimestamps,uint32"`
+	InTimestampReps  uint64 `kstat:"inTimestampReps,uint32"`
+	InAddrMasks      uint64 `kstat:"inAddrMasks,uint32"`
+	InAddrMaskReps   uint64 `kstat:"inAddrMaskReps,uint32"`
+	InFragNeeded     uint64 `kstat:"inFragNeeded,uint32"`
+	OutMsgs          uint64 `kstat:"outMsgs,uint32"`
+	OutDrops         uint64 `kstat:"outDrops,uint32"`
+	OutErrors        uint64 `kstat:"outErrors,uint32"`
+	OutDestUnreachs  uint64 `kstat:"outDestUnreachs,uint32"`
+	OutTimeExcds     uint64 `kstat:"outTimeExcds,uint32"`
+	OutParmProbs     uint64 `kstat:"outParmProbs,uint32"`
+	OutSrcQuenchs    uint64 `kstat:"outSrcQuenchs,uint32"`
+	OutRedirects     uint64 `kstat:"outRedirects,uint32"`
+	OutEchos         uint64 `kstat:"outEchos,uint32"`
+	OutEchoReps      uint64 `kstat:"outEchoReps,uint32"`
+	OutTimestamps    uint64 `kstat:"outTimestamps,uint32"`
+	OutTimestampReps uint64 `kstat:"outTimestampReps,uint32"`
+	OutAddrMasks     uint64 `kstat:"outAddrMasks,uint32"`
+	OutAddrMaskReps  uint64 `kstat:"outAddrMaskReps,uint32"`
+	OutFragNeeded    uint64 `kstat:"outFragNeeded,uint32"`
+	InOverflows      uint64 `kstat:"inOverflows,uint32"`
+}
+
+// IPStats is the IPv4 MIB-II statistics from ip:0:ip, including the
+// few UDP and TCP statistics that illumos counts in IP.
+type IPStats struct {
+	Snaptime int64 `kstat:"snaptime"`
+
+	Forwarding       int64  `kstat:"forwarding,gauge"`
+	DefaultTTL       int64  `kstat:"defaultTTL,gauge"`
+	InReceives       uint64 `kstat:"inReceives"`
+	InHdrErrors      uint64 `kstat:"inHdrErrors,uint32"`
+	InAddrErrors     uint64 `kstat:"inAddrErrors,uint32"`
+	InCksumErrs      uint64 `kstat:"inCksumErrs,uint32"`
+	ForwDatagrams    uint64 `kstat:"forwDatagrams"`
+	ForwProhibits    uint64 `kstat:"forwProhibits,uint32"`
+	InUnknownProtos  uint64 `kstat:"inUnknownProtos,uint32"`
+	InDiscards       uint64 `kstat:"inDiscards,uint32"`
+	InDelivers       uint64 `kstat:"inDelivers"`
+	OutRequests      uint64 `kstat:"outRequests"`
+	OutDiscards      uint64 `kstat:"outDiscards,uint32"`
+	OutNoRoutes      uint64 `kstat:"outNoRoutes,uint32"`
+	ReasmTimeout     int64  `kstat:"reasmTimeout,gauge"`
+	ReasmReqds       uint64 `kstat:"reasmReqds,uint32"`
+	ReasmOKs         uint64 `kstat:"reasmOKs,uint32"`
+	ReasmFails       uint64 `kstat:"reasmFails,uint32"`
+	ReasmDuplicates  uint64 `kstat:"reasmDuplicates,uint32"`
+	ReasmPartDups    uint64 `kstat:"reasmPartDups,uint32"`
+	FragOKs          uint64 `kstat:"fragOKs,uint32"`
+	FragFails        uint64 `kstat:"fragFails,uint32"`
+	FragCreates      uint64 `kstat:"fragCreates,uint32"`
+	RoutingDiscards  uint64 `kstat:"routingDiscards,uint32"`
+	TCPInErrs        uint64 `kstat:"tcpInErrs,uint32"`
+	UDPNoPorts       uint64 `kstat:"udpNoPorts,uint32"`
+	UDPInCksumErrs   uint64 `kstat:"udpInCksumErrs,uint32"`
+	UDPInOverflows   uint64 `kstat:"udpInOverflows,uint32"`
+	RawipInOverflows uint64 `kstat:"rawipInOverflows,uint32"`
+}
+
+// mibLabel returns the netstat -s label for a statistic of protocol
+// proto, eg "tcpActiveOpens" for "activeOpens". Statistics that are
+// already named for a protocol, like IP's "udpNoPorts", are left
+// alone.
+func mibLabel(proto, stat string) string {
+	for _, p := range []string{"tcp", "udp", "rawip", "ipsec"} {
+		if strings.HasPrefix(stat, p) {
+			return stat
+		}
+	}
+	return proto + strings.ToUpper(stat[:1]) + stat[1:]
+}
+
+// writeMib writes one protocol section of a netstat -s report for the
+// typed statistics struct v to b, with two statistics per line.
+func writeMib(b *strings.Builder, title, proto string, v interface{}) {
+	rv := reflect.ValueOf(v).Elem()
+	vt := rv.Type()
+	fmt.Fprintf(b, "\n%s", title)
+	n := 0
+	for i := 0; i < vt.NumField(); i++ {
+		name, _ := statTag(vt.Field(i))
+		if name == "" || name == "snaptime" {
+			continue
+		}
+		var val string
+		switch f := rv.Field(i); f.Kind() {
+		case reflect.Int64:
+			val = fmt.Sprintf("%d", f.Int())
+		default:
+			val = fmt.Sprintf("%d", f.Uint())
+		}
+		fmt.Fprintf(b, "\t%-20s=%10s", mibLabel(proto, name), val)
+		if n%2 == 1 {
+			b.WriteString("\n")
+		}
+		n++
+	}
+	if n%2 == 1 {
+		b.WriteString("\n")
+	}
+}
+
+// WriteNetstatS writes a netstat -s style report of the IPv4 MIB-II
+// statistics to w. Any of the arguments may be nil, in which case its
+// section is omitted.
+func WriteNetstatS(w io.Writer, tcp *TCPStats, udp *UDPStats, ip *IPStats, icmp *ICMPStats) error {
+	var b strings.Builder
+	if udp != nil {
+		writeMib(&b, "UDP", "udp", udp)
+	}
+	if tcp != nil {
+		writeMib(&b, "TCP", "tcp", tcp)
+	}
+	if ip != nil {
+		writeMib(&b, "IPv4", "ip", ip)
+	}
+	if icmp != nil {
+		writeMib(&b, "ICMPv4", "icmp", icmp)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,40 @@
+//
+// Retrieving the MIB-II statistics.
+
+package kstat
+
+// TCPStats returns the current TCP statistics from tcp:0:tcp.
+func (t *Token) TCPStats() (*TCPStats, error) {
+	s := TCPStats{}
+	if err := t.lookupFill("tcp", 0, "tcp", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UDPStats returns the current UDP statistics from udp:0:udp.
+func (t *Token) UDPStats() (*UDPStats, error) {
+	s := UDPStats{}
+	if err := t.lookupFill("udp", 0, "udp", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ICMPStats returns the current ICMP statistics from ip:0:icmp.
+func (t *Token) ICMPStats() (*ICMPStats, error) {
+	s := ICMPStats{}
+	if err := t.lookupFill("ip", 0, "icmp", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// IPStats returns the current IPv4 statistics from ip:0:ip.
+func (t *Token) IPStats() (*IPStats, error) {
+	s := IPStats{}
+	if err := t.lookupFill("ip", 0, "ip", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,33 @@
+//
+// Test retrieving the MIB-II statistics.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+func TestMIB(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	tcp, err := tok.TCPStats()
+	if err != nil {
+		t.Fatalf("TCPStats error: %s", err)
+	}
+	if tcp.Snaptime == 0 || tcp.RtoMax == 0 {
+		t.Fatalf("TCP stats are odd: %+v", tcp)
+	}
+	if _, err := tok.UDPStats(); err != nil {
+		t.Fatalf("UDPStats error: %s", err)
+	}
+	if _, err := tok.ICMPStats(); err != nil {
+		t.Fatalf("ICMPStats error: %s", err)
+	}
+	ip, err := tok.IPStats()
+	if err != nil {
+		t.Fatalf("IPStats error: %s", err)
+	}
+	if ip.DefaultTTL == 0 {
+		t.Fatalf("IP stats are odd: %+v", ip)
+	}
+}
@@ -0,0 +1,77 @@
+//
+// Test StatRates and the netstat -s report.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestStatRates(t *testing.T) {
+	sec := int64(time.Second)
+	prev := &kstat.TCPStats{Snaptime: sec, ActiveOpens: 10, CurrEstab: 5, MaxConn: -1}
+	cur := &kstat.TCPStats{Snaptime: 5 * sec, ActiveOpens: 30, CurrEstab: 7, MaxConn: -1, RetransSegs: 2}
+	r := kstat.StatRates(prev, cur)
+	if !near(r["activeOpens"], 5) || !near(r["retransSegs"], 0.5) {
+		t.Fatalf("bad counter rates: %v", r)
+	}
+	if r["currEstab"] != 7 || r["maxConn"] != -1 {
+		t.Fatalf("bad gauge values: %v", r)
+	}
+	if _, ok := r["snaptime"]; ok {
+		t.Fatalf("snaptime in rates: %v", r)
+	}
+
+	// 32-bit counters wrap around.
+	r = kstat.StatRates(&kstat.UDPStats{Snaptime: sec, InErrors: 0xfffffff0},
+		&kstat.UDPStats{Snaptime: 2 * sec, InErrors: 0x10})
+	if r["inErrors"] != 0x20 {
+		t.Fatalf("bad wrapped rate: %v", r)
+	}
+
+	// 64-bit counters that go backwards have been reset, and
+	// count up from zero, even if they were small enough to have
+	// been 32-bit counters.
+	r = kstat.StatRates(&kstat.TCPStats{Snaptime: sec, InSegs: 1 << 40, OutSegs: 1000},
+		&kstat.TCPStats{Snaptime: 2 * sec, InSegs: 12, OutSegs: 10})
+	if r["inSegs"] != 12 || r["outSegs"] != 10 {
+		t.Fatalf("bad reset rate: %v", r)
+	}
+}
+
+func TestStatRatesPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("StatRates of different types did not panic")
+		}
+	}()
+	kstat.StatRates(&kstat.TCPStats{}, &kstat.UDPStats{})
+}
+
+func TestWriteNetstatS(t *testing.T) {
+	var b bytes.Buffer
+	udp := &kstat.UDPStats{InDatagrams: 100, InErrors: 1, OutDatagrams: 99, EntrySize: 24}
+	if err := kstat.WriteNetstatS(&b, nil, udp, nil, nil); err != nil {
+		t.Fatalf("WriteNetstatS error: %s", err)
+	}
+	exp := "\nUDP\tudpInDatagrams      =       100\tudpInErrors         =         1\n" +
+		"\tudpOutDatagrams     =        99\tudpOutErrors        =         0\n" +
+		"\tudpEntrySize        =        24\tudpEntry6Size       =         0\n"
+	if b.String() != exp {
+		t.Fatalf("WriteNetstatS output wrong:\n%q\nexpected:\n%q", b.String(), exp)
+	}
+
+	b.Reset()
+	ip := &kstat.IPStats{Forwarding: 2, UDPNoPorts: 3}
+	if err := kstat.WriteNetstatS(&b, nil, nil, ip, nil); err != nil {
+		t.Fatalf("WriteNetstatS error: %s", err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("\tipForwarding        =         2")) ||
+		!bytes.Contains(b.Bytes(), []byte("\tudpNoPorts          =         3")) {
+		t.Fatalf("WriteNetstatS IP output wrong:\n%s", b.String())
+	}
+}
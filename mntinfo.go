@@ -0,0 +1,260 @@
+//
+// Decoding of the various bits of NFS mount information in Mntinfo,
+// and an 'nfsstat -m' style formatter for it.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MntFlags is the set of NFS client mount flags in Mntinfo.Flags.
+// These are the MI_* flags from nfs/nfs_clnt.h. Its String() method
+// renders them the way that 'nfsstat -m' does, eg 'hard,intr,acl'.
+type MntFlags uint32
+
+// The individual MntFlags.
+const (
+	MntHard        MntFlags = 0x1      // hard mount (otherwise soft)
+	MntPrinted     MntFlags = 0x2      // not responding message printed
+	MntIntr        MntFlags = 0x4      // interrupts allowed on hard mount
+	MntDown        MntFlags = 0x8      // server is down
+	MntNoac        MntFlags = 0x10     // don't cache attributes
+	MntNocto       MntFlags = 0x20     // no close-to-open consistency
+	MntDynamic     MntFlags = 0x40     // dynamic transfer size adjustment
+	MntLlock       MntFlags = 0x80     // local locking only (no lockmgr)
+	MntGrpid       MntFlags = 0x100    // System V group id inheritance
+	MntRPCTimesync MntFlags = 0x200    // RPC time sync
+	MntLink        MntFlags = 0x400    // server supports link
+	MntSymlink     MntFlags = 0x800    // server supports symlink
+	MntReaddirOnly MntFlags = 0x1000   // use readdir instead of readdirplus
+	MntACL         MntFlags = 0x2000   // server supports NFS_ACL
+	MntBindInProg  MntFlags = 0x4000   // binding to server is changing
+	MntLoopback    MntFlags = 0x8000   // this is a loopback mount
+	MntSemisoft    MntFlags = 0x10000  // soft reads, hard modify
+	MntNoprint     MntFlags = 0x20000  // don't print messages
+	MntDirectIO    MntFlags = 0x40000  // do direct I/O
+	MntExtattr     MntFlags = 0x80000  // server supports extended attrs
+	MntAsyncStop   MntFlags = 0x100000 // tell async manager to die
+	MntDead        MntFlags = 0x200000 // mount has been terminated
+)
+
+// The names of flags that are only mentioned when they're set, in
+// the order that nfsstat prints them.
+var mntFlagNames = []struct {
+	f    MntFlags
+	name string
+}{
+	{MntPrinted, "printed"},
+	{MntDown, "down"},
+	{MntNoac, "noac"},
+	{MntNocto, "nocto"},
+	{MntDynamic, "dynamic"},
+	{MntLlock, "llock"},
+	{MntGrpid, "grpid"},
+	{MntRPCTimesync, "rpctimesync"},
+	{MntLink, "link"},
+	{MntSymlink, "symlink"},
+	{MntReaddirOnly, "readdironly"},
+	{MntACL, "acl"},
+	{MntBindInProg, "bindinprog"},
+	{MntLoopback, "loopback"},
+	{MntSemisoft, "semisoft"},
+	{MntNoprint, "noprint"},
+	{MntDirectIO, "forcedirectio"},
+	{MntExtattr, "xattr"},
+	{MntAsyncStop, "asyncstop"},
+	{MntDead, "dead"},
+}
+
+// Has returns true if all of the flags in f2 are set in f.
+func (f MntFlags) Has(f2 MntFlags) bool {
+	return f&f2 == f2
+}
+
+// String renders f as 'nfsstat -m' does, as a comma separated list.
+// Hard versus soft and intr versus nointr are always present; other
+// flags are listed only if they are set. Unknown flags are given in
+// hex.
+func (f MntFlags) String() string {
+	var l []string
+	if f.Has(MntHard) {
+		l = append(l, "hard")
+	} else {
+		l = append(l, "soft")
+	}
+	if f.Has(MntIntr) {
+		l = append(l, "intr")
+	} else {
+		l = append(l, "nointr")
+	}
+	known := MntHard | MntIntr
+	for _, e := range mntFlagNames {
+		known |= e.f
+		if f.Has(e.f) {
+			l = append(l, e.name)
+		}
+	}
+	if rest := f &^ known; rest != 0 {
+		l = append(l, fmt.Sprintf("0x%x", uint32(rest)))
+	}
+	return strings.Join(l, ",")
+}
+
+// SecFlavor is the RPC security flavor of an NFS mount, from
+// Mntinfo.Secmod. For RPCSEC_GSS mounts this is the NFS security
+// mode number from nfssec.conf, which distinguishes the various
+// Kerberos flavours.
+type SecFlavor uint32
+
+// The security flavors that 'nfsstat -m' knows about by default.
+const (
+	SecNone  SecFlavor = 0
+	SecSys   SecFlavor = 1
+	SecShort SecFlavor = 2
+	SecDH    SecFlavor = 3
+	SecKrb4  SecFlavor = 4
+	SecKrb5  SecFlavor = 390003
+	SecKrb5i SecFlavor = 390004
+	SecKrb5p SecFlavor = 390005
+)
+
+func (s SecFlavor) String() string {
+	switch s {
+	case SecNone:
+		return "none"
+	case SecSys:
+		return "sys"
+	case SecShort:
+		return "short"
+	case SecDH:
+		return "dh"
+	case SecKrb4:
+		return "krb4"
+	case SecKrb5:
+		return "krb5"
+	case SecKrb5i:
+		return "krb5i"
+	case SecKrb5p:
+		return "krb5p"
+	default:
+		return fmt.Sprintf("sec-%d", uint32(s))
+	}
+}
+
+// MountFlags returns a Mntinfo Flags as a MntFlags.
+func (m Mntinfo) MountFlags() MntFlags {
+	return MntFlags(m.Flags)
+}
+
+// Security returns a Mntinfo Secmod as a SecFlavor.
+func (m Mntinfo) Security() SecFlavor {
+	return SecFlavor(m.Secmod)
+}
+
+// TimerClass selects one of the Mntinfo RPC round trip timers. The
+// kernel keeps separate timers for lookups, reads, and writes, and
+// one more for all other calls.
+type TimerClass int
+
+// The TimerClasses, which are also indexes into Mntinfo.Timers.
+const (
+	TimerAll TimerClass = iota
+	TimerLookup
+	TimerRead
+	TimerWrite
+)
+
+func (c TimerClass) String() string {
+	switch c {
+	case TimerAll:
+		return "All"
+	case TimerLookup:
+		return "Lookups"
+	case TimerRead:
+		return "Reads"
+	case TimerWrite:
+		return "Writes"
+	default:
+		return fmt.Sprintf("timer-%d", int(c))
+	}
+}
+
+// MntTimer is one Mntinfo RPC timer converted to durations.
+type MntTimer struct {
+	Srtt    time.Duration // smoothed round trip time
+	Deviate time.Duration // estimated deviation of the round trip time
+	Rtxcur  time.Duration // current (backed off) retransmit timeout
+}
+
+// The units of the raw timer values. These are the conversion factors
+// that nfsstat uses, which assume the normal 100 Hz system clock (the
+// raw values are scaled clock ticks).
+const (
+	srttUnit    = 2500 * time.Microsecond
+	deviateUnit = 5 * time.Millisecond
+	rtxcurUnit  = 20 * time.Millisecond
+)
+
+// Timer returns the RPC timer values for a particular class of
+// operation. Asking for an invalid TimerClass returns zero values.
+// The timers are only maintained for NFS v2 and v3 mounts.
+func (m Mntinfo) Timer(c TimerClass) MntTimer {
+	if c < TimerAll || c > TimerWrite {
+		return MntTimer{}
+	}
+	t := m.Timers[c]
+	return MntTimer{
+		Srtt:    time.Duration(t.Srtt) * srttUnit,
+		Deviate: time.Duration(t.Deviate) * deviateUnit,
+		Rtxcur:  time.Duration(t.Rtxcur) * rtxcurUnit,
+	}
+}
+
+// WriteNfsstat writes the information in m to w in the format used
+// by 'nfsstat -m'. Mntinfo doesn't know the mount point or what's
+// mounted on it, so you have to supply these yourself (eg from
+// /etc/mnttab); if mntpoint is "", the '<mntpoint> from <resource>'
+// header line is omitted.
+func (m Mntinfo) WriteNfsstat(w io.Writer, mntpoint, resource string) error {
+	var b strings.Builder
+	if mntpoint != "" {
+		fmt.Fprintf(&b, "%s from %s\n", mntpoint, resource)
+	}
+	flags := m.MountFlags()
+	if m.Vers >= 4 {
+		// readdironly is meaningless for NFS v4.
+		flags &^= MntReaddirOnly
+	}
+	fmt.Fprintf(&b, " Flags:\t\tvers=%d,proto=%s,sec=%s,%s,rsize=%d,wsize=%d,retrans=%d,timeo=%d\n",
+		m.Vers, m.Proto(), m.Security(), flags, m.Curread, m.Curwrite, m.Retrans, m.Timeo)
+	fmt.Fprintf(&b, " Attr cache:\tacregmin=%d,acregmax=%d,acdirmin=%d,acdirmax=%d\n",
+		m.Acregmin, m.Acregmax, m.Acdirmin, m.Acdirmax)
+	if m.Failover != 0 || m.Remap != 0 || m.Noresponse != 0 {
+		fmt.Fprintf(&b, " Failover:\tnoresponse=%d,failover=%d,remap=%d,currserver=%s\n",
+			m.Noresponse, m.Failover, m.Remap, m.Curserver())
+	}
+	if m.Vers < 4 {
+		// nfsstat prints the 'All' timer last.
+		for _, c := range []TimerClass{TimerLookup, TimerRead, TimerWrite, TimerAll} {
+			r := m.Timers[c]
+			if r.Srtt == 0 && r.Rtxcur == 0 {
+				continue
+			}
+			t := m.Timer(c)
+			tabs := "\t"
+			if len(c.String()) < 6 {
+				tabs = "\t\t"
+			}
+			fmt.Fprintf(&b, " %s:%ssrtt=%d (%dms), dev=%d (%dms), cur=%d (%dms)\n", c, tabs,
+				r.Srtt, t.Srtt/time.Millisecond, r.Deviate, t.Deviate/time.Millisecond,
+				r.Rtxcur, t.Rtxcur/time.Millisecond)
+		}
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
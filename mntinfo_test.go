@@ -0,0 +1,94 @@
+//
+// Test the decoding and formatting of Mntinfo fields. These don't need
+// any actual NFS mounts.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestMntFlags(t *testing.T) {
+	f := kstat.MntHard | kstat.MntIntr | kstat.MntLlock | kstat.MntACL
+	if s := f.String(); s != "hard,intr,llock,acl" {
+		t.Fatalf("bad flags string for 0x%x: %q", uint32(f), s)
+	}
+	f = kstat.MntGrpid | kstat.MntFlags(0x80000000)
+	if s := f.String(); s != "soft,nointr,grpid,0x80000000" {
+		t.Fatalf("bad flags string for 0x%x: %q", uint32(f), s)
+	}
+	if !f.Has(kstat.MntGrpid) || f.Has(kstat.MntGrpid|kstat.MntHard) {
+		t.Fatalf("Has is wrong for 0x%x", uint32(f))
+	}
+}
+
+func TestSecFlavor(t *testing.T) {
+	mi := kstat.Mntinfo{Secmod: 390005}
+	if s := mi.Security().String(); s != "krb5p" {
+		t.Fatalf("390005 is not krb5p: %q", s)
+	}
+	if s := kstat.SecFlavor(1).String(); s != "sys" {
+		t.Fatalf("1 is not sys: %q", s)
+	}
+	if s := kstat.SecFlavor(77).String(); s != "sec-77" {
+		t.Fatalf("bad unknown flavor: %q", s)
+	}
+}
+
+// testMntinfo makes a plausible NFS v3 Mntinfo.
+func testMntinfo() kstat.Mntinfo {
+	mi := kstat.Mntinfo{
+		Vers:     3,
+		Flags:    uint32(kstat.MntHard | kstat.MntIntr | kstat.MntLink | kstat.MntSymlink | kstat.MntACL),
+		Secmod:   1,
+		Curread:  32768,
+		Curwrite: 32768,
+		Timeo:    600,
+		Retrans:  5,
+		Acregmin: 3,
+		Acregmax: 60,
+		Acdirmin: 30,
+		Acdirmax: 60,
+	}
+	copy(mi.RProto[:], toint8("tcp")[:])
+	copy(mi.RCurserver[:], toint8("fileserver")[:])
+	mi.Timers[kstat.TimerLookup].Srtt = 7
+	mi.Timers[kstat.TimerLookup].Deviate = 3
+	mi.Timers[kstat.TimerLookup].Rtxcur = 2
+	return mi
+}
+
+func TestMntTimer(t *testing.T) {
+	mi := testMntinfo()
+	tm := mi.Timer(kstat.TimerLookup)
+	if tm.Srtt != 17500*time.Microsecond || tm.Deviate != 15*time.Millisecond || tm.Rtxcur != 40*time.Millisecond {
+		t.Fatalf("bad lookup timer: %+v", tm)
+	}
+	if tm = mi.Timer(kstat.TimerRead); tm != (kstat.MntTimer{}) {
+		t.Fatalf("read timer is not zero: %+v", tm)
+	}
+	if tm = mi.Timer(kstat.TimerClass(10)); tm != (kstat.MntTimer{}) {
+		t.Fatalf("bad timer class gives non-zero timer: %+v", tm)
+	}
+}
+
+func TestWriteNfsstat(t *testing.T) {
+	mi := testMntinfo()
+	var b bytes.Buffer
+	err := mi.WriteNfsstat(&b, "/h/1", "fileserver:/h/1")
+	if err != nil {
+		t.Fatalf("WriteNfsstat error: %s", err)
+	}
+	exp := "/h/1 from fileserver:/h/1\n" +
+		" Flags:\t\tvers=3,proto=tcp,sec=sys,hard,intr,link,symlink,acl,rsize=32768,wsize=32768,retrans=5,timeo=600\n" +
+		" Attr cache:\tacregmin=3,acregmax=60,acdirmin=30,acdirmax=60\n" +
+		" Lookups:\tsrtt=7 (17ms), dev=3 (15ms), cur=2 (40ms)\n" +
+		"\n"
+	if b.String() != exp {
+		t.Fatalf("WriteNfsstat output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
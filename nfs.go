@@ -0,0 +1,273 @@
+//
+// Typed access to the NFS client and server operation counts in the
+// nfs:0:rfsreqcnt_v*, rfsproccnt_v*, aclreqcnt_v*, and aclproccnt_v*
+// kstats and to the RPC statistics in unix:0:rpc_*, plus nfsstat -c
+// and -s style reports of them.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// NFSOp is the count of one NFS operation.
+type NFSOp struct {
+	Name  string
+	Count uint64
+}
+
+// NFSOps is the operation counts for one version of NFS (or of the
+// NFS ACL protocol) on either the client or the server side. Ops is
+// in the order that the kernel has them in, which is the order of
+// the operations in the protocol and the order nfsstat prints them.
+type NFSOps struct {
+	Version  int
+	Snaptime int64
+	Ops      []NFSOp
+}
+
+// Total returns the total count of all operations.
+func (o *NFSOps) Total() uint64 {
+	var t uint64
+	for _, op := range o.Ops {
+		t += op.Count
+	}
+	return t
+}
+
+// NFSOpRate is the per-second rate of one NFS operation.
+type NFSOpRate struct {
+	Name string
+	Rate float64
+}
+
+// NFSOpRates is the per-second rates of NFS operations over an
+// interval, in the same order as NFSOps.
+type NFSOpRates struct {
+	Version  int
+	Interval time.Duration
+	Total    float64
+	Ops      []NFSOpRate
+}
+
+// RatesSince computes o's operation rates over the interval between
+// prev and o. prev should be for the same version and side; any
+// operations it doesn't have are treated as starting from zero.
+func (o *NFSOps) RatesSince(prev *NFSOps) *NFSOpRates {
+	d := snapInterval(prev.Snaptime, o.Snaptime)
+	pm := make(map[string]uint64, len(prev.Ops))
+	for _, op := range prev.Ops {
+		pm[op.Name] = op.Count
+	}
+	r := NFSOpRates{Version: o.Version, Interval: d}
+	for _, op := range o.Ops {
+		rt := perSecond(pm[op.Name], op.Count, 64, d)
+		r.Ops = append(r.Ops, NFSOpRate{op.Name, rt})
+		r.Total += rt
+	}
+	return &r
+}
+
+// RPCCotsClient is the connection oriented (TCP) RPC client
+// statistics from unix:0:rpc_cots_client.
+type RPCCotsClient struct {
+	Snaptime   int64  `kstat:"snaptime"`
+	Calls      uint64 `kstat:"calls"`
+	Badcalls   uint64 `kstat:"badcalls"`
+	Badxids    uint64 `kstat:"badxids"`
+	Timeouts   uint64 `kstat:"timeouts"`
+	Newcreds   uint64 `kstat:"newcreds"`
+	Badverfs   uint64 `kstat:"badverfs"`
+	Timers     uint64 `kstat:"timers"`
+	Cantconn   uint64 `kstat:"cantconn"`
+	Nomem      uint64 `kstat:"nomem"`
+	Interrupts uint64 `kstat:"interrupts"`
+}
+
+// RPCCltsClient is the connectionless (UDP) RPC client statistics
+// from unix:0:rpc_clts_client.
+type RPCCltsClient struct {
+	Snaptime int64  `kstat:"snaptime"`
+	Calls    uint64 `kstat:"calls"`
+	Badcalls uint64 `kstat:"badcalls"`
+	Retrans  uint64 `kstat:"retrans"`
+	Badxids  uint64 `kstat:"badxids"`
+	Timeouts uint64 `kstat:"timeouts"`
+	Newcreds uint64 `kstat:"newcreds"`
+	Badverfs uint64 `kstat:"badverfs"`
+	Timers   uint64 `kstat:"timers"`
+	Nomem    uint64 `kstat:"nomem"`
+	Cantsend uint64 `kstat:"cantsend"`
+}
+
+// RPCServer is the RPC server statistics from unix:0:rpc_cots_server
+// or unix:0:rpc_clts_server, which have the same statistics.
+type RPCServer struct {
+	Snaptime  int64  `kstat:"snaptime"`
+	Calls     uint64 `kstat:"calls"`
+	Badcalls  uint64 `kstat:"badcalls"`
+	Nullrecv  uint64 `kstat:"nullrecv"`
+	Badlen    uint64 `kstat:"badlen"`
+	Xdrcall   uint64 `kstat:"xdrcall"`
+	Dupchecks uint64 `kstat:"dupchecks"`
+	Dupreqs   uint64 `kstat:"dupreqs"`
+}
+
+// NFSClientCalls is the overall NFS client statistics from
+// nfs:0:nfs_client.
+type NFSClientCalls struct {
+	Snaptime  int64  `kstat:"snaptime"`
+	Calls     uint64 `kstat:"calls"`
+	Badcalls  uint64 `kstat:"badcalls"`
+	Clgets    uint64 `kstat:"clgets"`
+	Cltoomany uint64 `kstat:"cltoomany"`
+}
+
+// NFSServerCalls is the overall NFS server statistics from
+// nfs:0:nfs_server.
+type NFSServerCalls struct {
+	Snaptime   int64  `kstat:"snaptime"`
+	Calls      uint64 `kstat:"calls"`
+	Badcalls   uint64 `kstat:"badcalls"`
+	Referrals  uint64 `kstat:"referrals"`
+	Referlinks uint64 `kstat:"referlinks"`
+}
+
+// NFSClientStats is all of the NFS client statistics. Anything may
+// be nil or empty if the system doesn't have the kstat for it, for
+// example because NFS has never been used. Ops and ACLOps are sorted
+// by version. Per-second rates of the RPC and call statistics can be
+// computed with StatRates().
+type NFSClientStats struct {
+	RPCCots *RPCCotsClient
+	RPCClts *RPCCltsClient
+	Calls   *NFSClientCalls
+	Ops     []*NFSOps
+	ACLOps  []*NFSOps
+}
+
+// NFSServerStats is all of the NFS server statistics, in the same
+// way as NFSClientStats.
+type NFSServerStats struct {
+	RPCCots *RPCServer
+	RPCClts *RPCServer
+	Calls   *NFSServerCalls
+	Ops     []*NFSOps
+	ACLOps  []*NFSOps
+}
+
+// nfsstatWidth and nfsstatPer are how wide each column is and how
+// many columns there are in nfsstat output, first for general
+// statistics and then for operation counts.
+const (
+	nfsstatWidth   = 11
+	nfsstatPer     = 7
+	nfsstatOpWidth = 12
+	nfsstatOpPer   = 6
+)
+
+// writeColumns writes names and values as nfsstat does, as pairs of
+// lines of per columns each with the names above the values.
+func writeColumns(b *strings.Builder, names, vals []string, width, per int) {
+	for i := 0; i < len(names); i += per {
+		j := i + per
+		if j > len(names) {
+			j = len(names)
+		}
+		for _, l := range [][]string{names[i:j], vals[i:j]} {
+			var line string
+			for _, s := range l {
+				line += fmt.Sprintf("%-*s ", width-1, s)
+			}
+			b.WriteString(strings.TrimRight(line, " "))
+			b.WriteString("\n")
+		}
+	}
+}
+
+// writeCounts writes the statistics of one of the typed RPC or NFS
+// call statistics structs.
+func writeCounts(b *strings.Builder, v interface{}) {
+	rv := reflect.ValueOf(v).Elem()
+	vt := rv.Type()
+	var names, vals []string
+	for i := 0; i < vt.NumField(); i++ {
+		name, _ := statTag(vt.Field(i))
+		if name == "" || name == "snaptime" {
+			continue
+		}
+		names = append(names, name)
+		vals = append(vals, fmt.Sprintf("%d", rv.Field(i).Uint()))
+	}
+	writeColumns(b, names, vals, nfsstatWidth, nfsstatPer)
+}
+
+// writeOps writes operation counts in nfsstat's format.
+func writeOps(b *strings.Builder, ol []*NFSOps) {
+	for _, o := range ol {
+		total := o.Total()
+		fmt.Fprintf(b, "Version %d: (%d calls)\n", o.Version, total)
+		var names, vals []string
+		for _, op := range o.Ops {
+			names = append(names, op.Name)
+			p := 0
+			if total > 0 {
+				p = int(op.Count * 100 / total)
+			}
+			vals = append(vals, fmt.Sprintf("%d %d%%", op.Count, p))
+		}
+		writeColumns(b, names, vals, nfsstatOpWidth, nfsstatOpPer)
+		b.WriteString("\n")
+	}
+}
+
+// writeRPC writes the RPC section of nfsstat output.
+func writeRPC(b *strings.Builder, side string, cots, clts interface{}) {
+	fmt.Fprintf(b, "\n%s rpc:\n", side)
+	if !reflect.ValueOf(cots).IsNil() {
+		b.WriteString("Connection oriented:\n")
+		writeCounts(b, cots)
+	}
+	if !reflect.ValueOf(clts).IsNil() {
+		b.WriteString("Connectionless:\n")
+		writeCounts(b, clts)
+	}
+}
+
+// WriteNfsstatC writes s to w in the format of nfsstat -c.
+func WriteNfsstatC(w io.Writer, s *NFSClientStats) error {
+	var b strings.Builder
+	writeRPC(&b, "Client", s.RPCCots, s.RPCClts)
+	b.WriteString("\nClient nfs:\n")
+	if s.Calls != nil {
+		writeCounts(&b, s.Calls)
+	}
+	writeOps(&b, s.Ops)
+	if len(s.ACLOps) > 0 {
+		b.WriteString("Client nfs_acl:\n")
+		writeOps(&b, s.ACLOps)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteNfsstatS writes s to w in the format of nfsstat -s.
+func WriteNfsstatS(w io.Writer, s *NFSServerStats) error {
+	var b strings.Builder
+	writeRPC(&b, "Server", s.RPCCots, s.RPCClts)
+	b.WriteString("\nServer NFS:\n")
+	if s.Calls != nil {
+		writeCounts(&b, s.Calls)
+	}
+	writeOps(&b, s.Ops)
+	if len(s.ACLOps) > 0 {
+		b.WriteString("Server NFS ACL:\n")
+		writeOps(&b, s.ACLOps)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
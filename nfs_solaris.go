@@ -0,0 +1,165 @@
+//
+// Retrieving NFS client and server statistics.
+
+package kstat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// nfsOps returns the operation counts from an nfs:0:<prefix>_v<N>
+// kstat. k is refreshed.
+func (k *KStat) nfsOps(prefix string) (*NFSOps, error) {
+	v, err := strconv.Atoi(strings.TrimPrefix(k.Name, prefix+"_v"))
+	if err != nil {
+		return nil, fmt.Errorf("kstat %s has a bad NFS version", k)
+	}
+	if err := k.Refresh(); err != nil {
+		return nil, err
+	}
+	lst, err := k.AllNamed()
+	if err != nil {
+		return nil, err
+	}
+	o := NFSOps{Version: v, Snaptime: k.Snaptime}
+	for _, n := range lst {
+		switch n.Type {
+		case Uint32, Uint64:
+			o.Ops = append(o.Ops, NFSOp{n.Name, n.UintVal})
+		case Int32, Int64:
+			o.Ops = append(o.Ops, NFSOp{n.Name, uint64(n.IntVal)})
+		default:
+			return nil, fmt.Errorf("kstat %s statistic %s has non-integer type %s", k, n.Name, n.Type)
+		}
+	}
+	return &o, nil
+}
+
+// nfsKStats returns the NFS and RPC related kstats that currently
+// exist, indexed by name. All of them are instance 0.
+func (t *Token) nfsKStats() map[string]*KStat {
+	res := make(map[string]*KStat)
+	for _, k := range t.All() {
+		if k.Type == NamedStat && k.Instance == 0 &&
+			(k.Module == "nfs" || k.Module == "nfs_acl" ||
+				(k.Module == "unix" && strings.HasPrefix(k.Name, "rpc_"))) {
+			res[k.Name] = k
+		}
+	}
+	return res
+}
+
+// gatherOps returns the operation counts from all of the kstats in
+// km whose names start with prefix, sorted by version. Kstats that
+// have disappeared are skipped.
+func gatherOps(km map[string]*KStat, prefix string) ([]*NFSOps, error) {
+	var res []*NFSOps
+	for name, k := range km {
+		if !strings.HasPrefix(name, prefix+"_v") {
+			continue
+		}
+		o, err := k.nfsOps(prefix)
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, o)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// fillNamed refreshes the kstat called name in km and fills in dst
+// from it, returning false if there is no such kstat (including if it
+// has disappeared).
+func fillNamed(km map[string]*KStat, name string, dst interface{}) (bool, error) {
+	k, ok := km[name]
+	if !ok {
+		return false, nil
+	}
+	err := k.Refresh()
+	if err == syscall.ENXIO {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := k.fill(dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NFSClientStats returns the current NFS client statistics. Each
+// kstat is refreshed as it's read.
+//
+// The NFS kstats are only created when the NFS modules are loaded,
+// so you may need to call Update() first. Kstats that have
+// disappeared since then are treated as missing.
+func (t *Token) NFSClientStats() (*NFSClientStats, error) {
+	km := t.nfsKStats()
+	s := NFSClientStats{}
+	cots, clts, calls := &RPCCotsClient{}, &RPCCltsClient{}, &NFSClientCalls{}
+	if ok, err := fillNamed(km, "rpc_cots_client", cots); err != nil {
+		return nil, err
+	} else if ok {
+		s.RPCCots = cots
+	}
+	if ok, err := fillNamed(km, "rpc_clts_client", clts); err != nil {
+		return nil, err
+	} else if ok {
+		s.RPCClts = clts
+	}
+	if ok, err := fillNamed(km, "nfs_client", calls); err != nil {
+		return nil, err
+	} else if ok {
+		s.Calls = calls
+	}
+
+	var err error
+	if s.Ops, err = gatherOps(km, "rfsreqcnt"); err != nil {
+		return nil, err
+	}
+	if s.ACLOps, err = gatherOps(km, "aclreqcnt"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// NFSServerStats returns the current NFS server statistics, in the
+// same way as NFSClientStats.
+func (t *Token) NFSServerStats() (*NFSServerStats, error) {
+	km := t.nfsKStats()
+	s := NFSServerStats{}
+	cots, clts, calls := &RPCServer{}, &RPCServer{}, &NFSServerCalls{}
+	if ok, err := fillNamed(km, "rpc_cots_server", cots); err != nil {
+		return nil, err
+	} else if ok {
+		s.RPCCots = cots
+	}
+	if ok, err := fillNamed(km, "rpc_clts_server", clts); err != nil {
+		return nil, err
+	} else if ok {
+		s.RPCClts = clts
+	}
+	if ok, err := fillNamed(km, "nfs_server", calls); err != nil {
+		return nil, err
+	} else if ok {
+		s.Calls = calls
+	}
+
+	var err error
+	if s.Ops, err = gatherOps(km, "rfsproccnt"); err != nil {
+		return nil, err
+	}
+	if s.ACLOps, err = gatherOps(km, "aclproccnt"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
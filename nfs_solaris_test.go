@@ -0,0 +1,30 @@
+//
+// Test retrieving NFS client and server statistics.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+// NFS may not be loaded, so all we can check is that we get
+// consistent results for whatever is there.
+func TestNFSStats(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	c, err := tok.NFSClientStats()
+	if err != nil {
+		t.Fatalf("NFSClientStats error: %s", err)
+	}
+	if len(c.Ops) == 0 {
+		t.Skip("skipping test due to no NFS client kstats")
+	}
+	for i, o := range c.Ops {
+		if len(o.Ops) == 0 || o.Snaptime == 0 || (i > 0 && o.Version <= c.Ops[i-1].Version) {
+			t.Fatalf("NFS client ops are odd: %+v", o)
+		}
+	}
+	if _, err := tok.NFSServerStats(); err != nil {
+		t.Fatalf("NFSServerStats error: %s", err)
+	}
+}
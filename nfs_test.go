@@ -0,0 +1,79 @@
+//
+// Test NFS operation rates and the nfsstat reports.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestNFSOpRates(t *testing.T) {
+	sec := int64(time.Second)
+	prev := &kstat.NFSOps{Version: 3, Snaptime: sec, Ops: []kstat.NFSOp{{"null", 0}, {"getattr", 10}}}
+	cur := &kstat.NFSOps{Version: 3, Snaptime: 3 * sec, Ops: []kstat.NFSOp{{"null", 0}, {"getattr", 30}, {"lookup", 4}}}
+	if cur.Total() != 34 {
+		t.Fatalf("wrong Total: %d", cur.Total())
+	}
+	r := cur.RatesSince(prev)
+	if r.Interval != 2*time.Second || r.Total != 12 || len(r.Ops) != 3 ||
+		r.Ops[1] != (kstat.NFSOpRate{"getattr", 10}) || r.Ops[2] != (kstat.NFSOpRate{"lookup", 2}) {
+		t.Fatalf("bad rates: %+v", r)
+	}
+}
+
+func TestWriteNfsstatC(t *testing.T) {
+	s := &kstat.NFSClientStats{
+		RPCCots: &kstat.RPCCotsClient{Calls: 1188, Timeouts: 2},
+		Calls:   &kstat.NFSClientCalls{Calls: 1100, Clgets: 1100},
+		Ops: []*kstat.NFSOps{
+			{Version: 3, Ops: []kstat.NFSOp{{"null", 0}, {"getattr", 51}, {"setattr", 0},
+				{"lookup", 2}, {"access", 10}, {"readlink", 0}, {"read", 10}}},
+		},
+	}
+	var b bytes.Buffer
+	if err := kstat.WriteNfsstatC(&b, s); err != nil {
+		t.Fatalf("WriteNfsstatC error: %s", err)
+	}
+	exp := "\nClient rpc:\nConnection oriented:\n" +
+		"calls      badcalls   badxids    timeouts   newcreds   badverfs   timers\n" +
+		"1188       0          0          2          0          0          0\n" +
+		"cantconn   nomem      interrupts\n" +
+		"0          0          0\n" +
+		"\nClient nfs:\n" +
+		"calls      badcalls   clgets     cltoomany\n" +
+		"1100       0          1100       0\n" +
+		"Version 3: (73 calls)\n" +
+		"null        getattr     setattr     lookup      access      readlink\n" +
+		"0 0%        51 69%      0 0%        2 2%        10 13%      0 0%\n" +
+		"read\n" +
+		"10 13%\n\n"
+	if b.String() != exp {
+		t.Fatalf("WriteNfsstatC output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
+
+func TestWriteNfsstatS(t *testing.T) {
+	s := &kstat.NFSServerStats{
+		RPCClts: &kstat.RPCServer{Calls: 5},
+		ACLOps:  []*kstat.NFSOps{{Version: 3, Ops: []kstat.NFSOp{{"null", 0}, {"getacl", 5}}}},
+	}
+	var b bytes.Buffer
+	if err := kstat.WriteNfsstatS(&b, s); err != nil {
+		t.Fatalf("WriteNfsstatS error: %s", err)
+	}
+	exp := "\nServer rpc:\nConnectionless:\n" +
+		"calls      badcalls   nullrecv   badlen     xdrcall    dupchecks  dupreqs\n" +
+		"5          0          0          0          0          0          0\n" +
+		"\nServer NFS:\n" +
+		"Server NFS ACL:\n" +
+		"Version 3: (5 calls)\n" +
+		"null        getacl\n" +
+		"0 0%        5 100%\n\n"
+	if b.String() != exp {
+		t.Fatalf("WriteNfsstatS output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
@@ -0,0 +1,93 @@
+//
+// Typed access to per-dataset ZFS I/O statistics, which illumos
+// exports as zfs:0:objset-0x* named kstats.
+
+package kstat
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// DatasetStats is the I/O statistics for one ZFS dataset, from a
+// zfs:0:objset-0x<id> named kstat. The counters count from when the
+// dataset was mounted (or otherwise became active).
+type DatasetStats struct {
+	// Module and Objset identify the kstat. Objset ids are only
+	// unique within a pool, so some versions put the pool name
+	// into the kstat module, eg 'zfs/tank'.
+	Module   string `kstat:"module"`
+	Objset   string `kstat:"name"`
+	Snaptime int64  `kstat:"snaptime"`
+
+	// Dataset is the full dataset name, eg 'tank/home/cks'.
+	Dataset string `kstat:"dataset_name"`
+
+	Reads     uint64 `kstat:"reads"`
+	Nread     uint64 `kstat:"nread"`
+	Writes    uint64 `kstat:"writes"`
+	Nwritten  uint64 `kstat:"nwritten"`
+	Nunlinks  uint64 `kstat:"nunlinks"`
+	Nunlinked uint64 `kstat:"nunlinked"`
+}
+
+// Pool returns the name of the pool that the dataset is in.
+func (d *DatasetStats) Pool() string {
+	if i := strings.IndexByte(d.Dataset, '/'); i >= 0 {
+		return d.Dataset[:i]
+	}
+	return d.Dataset
+}
+
+// DatasetRates is the per-second I/O rates of a ZFS dataset over an
+// interval.
+type DatasetRates struct {
+	Dataset  string
+	Interval time.Duration
+
+	Reads     float64 // read operations
+	Nread     float64 // bytes read
+	Writes    float64 // write operations
+	Nwritten  float64 // bytes written
+	Nunlinks  float64 // files queued for deletion
+	Nunlinked float64 // files actually deleted
+}
+
+// RatesSince computes the dataset's I/O rates over the interval
+// between prev and d. prev should be for the same dataset.
+func (d *DatasetStats) RatesSince(prev *DatasetStats) *DatasetRates {
+	iv := snapInterval(prev.Snaptime, d.Snaptime)
+	return &DatasetRates{
+		Dataset:   d.Dataset,
+		Interval:  iv,
+		Reads:     perSecond(prev.Reads, d.Reads, 64, iv),
+		Nread:     perSecond(prev.Nread, d.Nread, 64, iv),
+		Writes:    perSecond(prev.Writes, d.Writes, 64, iv),
+		Nwritten:  perSecond(prev.Nwritten, d.Nwritten, 64, iv),
+		Nunlinks:  perSecond(prev.Nunlinks, d.Nunlinks, 64, iv),
+		Nunlinked: perSecond(prev.Nunlinked, d.Nunlinked, 64, iv),
+	}
+}
+
+// DatasetRatesSince computes the rates of every dataset that is
+// present in both prev and cur, returning them sorted by dataset
+// name. Datasets are matched up by their kstat and their name; if an
+// objset id has been reused for a different dataset between the two
+// samples, it's skipped.
+func DatasetRatesSince(prev, cur []*DatasetStats) []*DatasetRates {
+	pm := make(map[string]*DatasetStats, len(prev))
+	for _, d := range prev {
+		pm[d.Module+":"+d.Objset] = d
+	}
+	var res []*DatasetRates
+	for _, d := range cur {
+		p, ok := pm[d.Module+":"+d.Objset]
+		if !ok || p.Dataset != d.Dataset {
+			continue
+		}
+		res = append(res, d.RatesSince(p))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Dataset < res[j].Dataset })
+	return res
+}
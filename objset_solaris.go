@@ -0,0 +1,44 @@
+//
+// Retrieving DatasetStats.
+
+package kstat
+
+import (
+	"strings"
+	"syscall"
+)
+
+// isObjset returns true if k is a per-dataset ZFS kstat.
+func (k *KStat) isObjset() bool {
+	return k.Type == NamedStat && (k.Module == "zfs" || strings.HasPrefix(k.Module, "zfs/")) &&
+		strings.HasPrefix(k.Name, "objset-")
+}
+
+// Datasets returns current I/O statistics for every ZFS dataset that
+// has a zfs:0:objset-* kstat, in no particular order. Each kstat is
+// refreshed as it's read, so the Snaptimes will differ slightly.
+//
+// You may want to call Update() first, since datasets come and go
+// as they are mounted and unmounted. Datasets whose kstats have
+// disappeared since then are skipped.
+func (t *Token) Datasets() ([]*DatasetStats, error) {
+	var res []*DatasetStats
+	for _, k := range t.All() {
+		if !k.isObjset() {
+			continue
+		}
+		err := k.Refresh()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d := DatasetStats{}
+		if err := k.fill(&d); err != nil {
+			return nil, err
+		}
+		res = append(res, &d)
+	}
+	return res, nil
+}
@@ -0,0 +1,25 @@
+//
+// Test retrieving DatasetStats, which requires ZFS datasets.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+func TestDatasets(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	dl, err := tok.Datasets()
+	if err != nil {
+		t.Fatalf("Datasets error: %s", err)
+	}
+	if len(dl) == 0 {
+		t.Skip("skipping test due to no objset kstats")
+	}
+	for _, d := range dl {
+		if d.Dataset == "" || d.Snaptime == 0 || d.Pool() == "" {
+			t.Fatalf("dataset stats are odd: %+v", d)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//
+// Test per-dataset rate calculations.
+
+package kstat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestDatasetRates(t *testing.T) {
+	sec := int64(time.Second)
+	prev := []*kstat.DatasetStats{
+		{Module: "zfs", Objset: "objset-0x36", Dataset: "tank/home", Snaptime: sec, Reads: 10, Nread: 4096},
+		{Module: "zfs", Objset: "objset-0x40", Dataset: "tank/gone", Snaptime: sec},
+		{Module: "zfs", Objset: "objset-0x41", Dataset: "tank/old", Snaptime: sec},
+	}
+	cur := []*kstat.DatasetStats{
+		{Module: "zfs", Objset: "objset-0x36", Dataset: "tank/home", Snaptime: 5 * sec, Reads: 30, Nread: 4096 + 4*8192, Writes: 8},
+		{Module: "zfs", Objset: "objset-0x41", Dataset: "tank/new", Snaptime: 5 * sec, Reads: 100},
+		{Module: "zfs", Objset: "objset-0x50", Dataset: "rpool/ROOT", Snaptime: 5 * sec},
+	}
+	rl := kstat.DatasetRatesSince(prev, cur)
+	if len(rl) != 1 {
+		t.Fatalf("wrong number of dataset rates: %d", len(rl))
+	}
+	r := rl[0]
+	if r.Dataset != "tank/home" || r.Interval != 4*time.Second || r.Reads != 5 || r.Nread != 8192 || r.Writes != 2 {
+		t.Fatalf("bad dataset rates: %+v", r)
+	}
+	if p := cur[2].Pool(); p != "rpool" {
+		t.Fatalf("bad pool for %s: %q", cur[2].Dataset, p)
+	}
+}
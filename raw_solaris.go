@@ -134,47 +134,18 @@ func (k *KStat) GetMntinfo() (*Mntinfo, error) {
 
 //
 // Support for copying semi-arbitrary structures out of raw
-// KStats.
+// KStats. The safety checks are in copy.go.
 //
 
-// safeThing returns true if a given type is either a simple defined
-// size primitive integer type or an array and/or struct composed
-// entirely of safe things. A safe thing is entirely self contained
-// and may be initialized from random memory without breaking Go's
-// memory safety (although the values it contains may be garbage).
-//
-func safeThing(t reflect.Type) bool {
-	switch t.Kind() {
-	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return true
-	case reflect.Array:
-		// an array is safe if it's an array of something safe
-		return safeThing(t.Elem())
-	case reflect.Struct:
-		// a struct is safe if all its components are safe
-		for i := 0; i < t.NumField(); i++ {
-			if !safeThing(t.Field(i).Type) {
-				return false
-			}
-		}
-		return true
-	default:
-		// other things are not safe.
-		return false
-	}
-}
-
-// TODO: add floats to the supported list? It's unlikely to be needed
-// but it should just work.
-
 // CopyTo copies a RawStat KStat into a struct that you supply a
 // pointer to. The size of the struct must exactly match the size of
 // the RawStat's data.
 //
 // CopyStat imposes conditions on the struct that you are copying to:
-// it must be composed entirely of primitive integer types with defined
-// sizes (intN and uintN), or arrays and structs that ultimately only
-// contain them. All fields should be exported.
+// it must be composed entirely of primitive integer and floating
+// point types with defined sizes (intN, uintN, and floatN), or arrays
+// and structs that ultimately only contain them. All fields should be
+// exported.
 //
 // If you give CopyStat a bad argument, it generally panics.
 //
@@ -188,30 +159,7 @@ func (k *KStat) CopyTo(ptr interface{}) error {
 		return errors.New("KStat is not a RawStat")
 	}
 
-	// Validity checks: not nil value, not nil pointer value,
-	// is a pointer to struct.
-	if ptr == nil {
-		panic("CopyTo given nil pointer")
-	}
-	vp := reflect.ValueOf(ptr)
-	if vp.Kind() != reflect.Ptr {
-		panic("CopyTo not given a pointer")
-	}
-	if vp.IsNil() {
-		panic("CopyTo given nil pointer")
-	}
-	dst := vp.Elem()
-	if dst.Kind() != reflect.Struct {
-		panic("CopyTo: not pointer to struct")
-	}
-	// Is the struct safe to copy into, which means primitive types
-	// and structs/arrays of primitive types?
-	if !safeThing(dst.Type()) {
-		panic("CopyTo: not a safe structure, contains unsupported fields")
-	}
-	if !dst.CanSet() {
-		panic("CopyTo: struct cannot be set for some reason")
-	}
+	dst := safeStructPtr(ptr, "CopyTo")
 
 	// Verify that the size of the target struct matches the size
 	// of the raw KStat.
@@ -234,3 +182,35 @@ func (k *KStat) CopyTo(ptr interface{}) error {
 
 	return nil
 }
+
+// CopyToSlice copies a RawStat KStat that is an array of fixed-size
+// records into a slice that you supply a pointer to, eg
+// &[]MemUnit{}. The slice is replaced with a newly allocated one
+// holding however many records the KStat currently has (which may be
+// none). The size of the raw data must be a multiple of the size of
+// the slice element type, and if the KStat uses ks_ndata as a record
+// count, the record size must match the element size.
+//
+// The slice element type must meet the same conditions as structs
+// for CopyTo, although it doesn't have to be a struct.
+//
+// If you give CopyToSlice a bad argument, it generally panics.
+//
+// This API is provisional and may be changed or deleted.
+func (k *KStat) CopyToSlice(ptr interface{}) error {
+	if err := k.prep(); err != nil {
+		return err
+	}
+
+	if k.Type != RawStat {
+		return errors.New("KStat is not a RawStat")
+	}
+
+	dst := safeSlicePtr(ptr, "CopyToSlice")
+	n, err := recordCount(uint64(k.ksp.ks_data_size), uint64(k.ksp.ks_ndata), dst.Type().Elem().Size())
+	if err != nil {
+		return fmt.Errorf("kstat %s: %s", k, err)
+	}
+	copyRecords(dst, unsafe.Pointer(k.ksp.ks_data), n)
+	return nil
+}
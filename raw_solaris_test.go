@@ -4,6 +4,7 @@
 package kstat_test
 
 import (
+	"math"
 	"testing"
 	"unsafe"
 
@@ -128,8 +129,9 @@ func TestCopyTo(t *testing.T) {
 		t.Fatalf("Var structure difference: Var: %+v CopyTo: %+v", or, r)
 	}
 
-	// Fetch an alternate version of the Sysinfo struct with CopyTo
-	// and verify it against the original.
+	// Fetch an alternate version of the Sysinfo struct (from
+	// registry_test.go) with CopyTo and verify it against the
+	// original.
 	// (We can't just use struct == because they're not the same
 	// types.)
 	ks, si, err := tok.Sysinfo()
@@ -153,23 +155,6 @@ func TestCopyTo(t *testing.T) {
 	}
 }
 
-// This has 6 uint32s, just like Sysinfo, but they are in a mixture of
-// unexported fields, embedded structs, and arrays. It doesn't cover
-// all combinations but it does cover a number of them.
-//
-// We pick updates as our unexported field because it's highly likely
-// to be non-zero, just in case. (I am probably worrying too much, and
-// the Swps often seem to be zero, so.)
-type Sysinfo_alt struct {
-	updates uint32
-	Runs    struct {
-		Runque uint32
-		runocc uint32
-	}
-	Swps    [2]uint32
-	Waiting uint32
-}
-
 // Testing GetMntinfo() is complicated by the fact that servers may
 // not have any of them.
 func TestMntinfo(t *testing.T) {
@@ -220,3 +205,79 @@ func TestMntinfoErrors(t *testing.T) {
 	}
 	stop(t, tok)
 }
+
+// Test CopyToSlice on mm:0:phys_installed, which is a variable sized
+// array of records that every machine should have at least one of,
+// and on unix:0:sysinfo, which is not a record array but is just a
+// bunch of uint32s. We also verify that Decode() agrees.
+func TestCopyToSlice(t *testing.T) {
+	tok := start(t)
+	ks := lookup(t, tok, "mm", "phys_installed")
+	var mu []kstat.MemUnit
+	err := ks.CopyToSlice(&mu)
+	if err != nil {
+		t.Fatalf("%s CopyToSlice failed: %s", ks, err)
+	}
+	if len(mu) == 0 {
+		t.Fatalf("%s has no memory units", ks)
+	}
+	for _, m := range mu {
+		if m.Size == 0 {
+			t.Fatalf("%s has zero-sized memory unit: %+v", ks, mu)
+		}
+	}
+	r, err := ks.Decode()
+	if err != nil {
+		t.Fatalf("%s Decode error: %s", ks, err)
+	}
+	if d, ok := r.([]kstat.MemUnit); !ok || len(d) != len(mu) || d[0] != mu[0] {
+		t.Fatalf("%s Decode disagrees with CopyToSlice: %#v vs %#v", ks, r, mu)
+	}
+
+	ks, si, err := tok.Sysinfo()
+	if err != nil {
+		t.Fatalf("Sysinfo() error: %s", err)
+	}
+	var u []uint32
+	err = ks.CopyToSlice(&u)
+	if err != nil {
+		t.Fatalf("%s CopyToSlice failed: %s", ks, err)
+	}
+	if len(u) != 6 || u[0] != si.Updates || u[5] != si.Waiting {
+		t.Fatalf("%s CopyToSlice values are wrong: %v vs %+v", ks, u, si)
+	}
+	// Sysinfo is not an even number of uint64s.
+	var u64 []uint64
+	err = ks.CopyToSlice(&u64)
+	if err == nil {
+		t.Fatalf("%s CopyToSlice of uint64s succeeded: %v", ks, u64)
+	}
+
+	stop(t, tok)
+
+	err = ks.CopyToSlice(&u)
+	if err == nil {
+		t.Fatalf("%s CopyToSlice succeeded after Close", ks)
+	}
+}
+
+// Floats are safe to copy into, even if the values are silly.
+func TestCopyToFloats(t *testing.T) {
+	tok := start(t)
+	ks, si, err := tok.Sysinfo()
+	if err != nil {
+		t.Fatalf("Sysinfo() error: %s", err)
+	}
+	f := struct {
+		Updates float32
+		Rest    [5]float32
+	}{}
+	err = ks.CopyTo(&f)
+	if err != nil {
+		t.Fatalf("%s CopyTo failed: %s", ks, err)
+	}
+	if math.Float32bits(f.Updates) != si.Updates {
+		t.Fatalf("%s float copy is wrong: %+v vs %+v", ks, f, si)
+	}
+	stop(t, tok)
+}
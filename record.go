@@ -0,0 +1,641 @@
+//
+// Recording Snapshots to a compact delta-encoded stream and reading
+// them back.
+//
+// A recording starts with recordMagic and is followed by frames, one
+// per Snapshot. A keyframe holds everything in its Snapshot; a delta
+// frame holds only the kstats whose data has changed since the
+// previous frame, and only their statistics that have changed, as
+// varint deltas. Kstats are given small ids when they first appear;
+// ids and everything else are reset at every keyframe, so a reader
+// can start at any keyframe. A RecordReader notes where the keyframes
+// it reads are, and if it can seek, At() uses this to go back.
+//
+// Frame:
+//	kind byte ('K' or 'D')
+//	time varint (keyframes: UnixNano; deltas: difference from last frame)
+//	removed uvarint, then that many kstat ids
+//	entries uvarint, then that many entries
+//
+// Entry:
+//	id uvarint
+//	flags byte
+//	if new: module string, instance varint, name string, class string,
+//		type byte, crtime varint
+//	snaptime varint and time varint, as differences from the previous
+//		ones for the kstat (or from zero if it's new)
+//	if full: count uvarint, then count of name string, type byte, value
+//	otherwise: count uvarint, then count of index uvarint, value delta
+//	if raw: raw data string
+//
+// Strings are a uvarint length followed by the bytes. IO kstats are
+// recorded as their named values (see Sample.Values()), with the io
+// flag set so that the reader can turn them back into an IO.
+
+package kstat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const recordMagic = "kstatrec1\n"
+
+// Entry flags.
+const (
+	recNew  = 1 << iota // the kstat's definition follows
+	recFull             // all statistics follow, with names
+	recRaw              // raw data follows
+	recIO               // the statistics are from an IO
+)
+
+// Limits on lengths in recordings, to catch corrupt ones before
+// they cause huge allocations.
+const (
+	maxRecString = 1 << 24
+	maxRecCount  = 1 << 20
+)
+
+// recState is what both the writer and the reader know about a kstat
+// in a recording.
+type recState struct {
+	id     uint64
+	sample Sample
+	vals   []NamedValue
+	io     bool
+}
+
+// Recorder writes Snapshots to a recording. Recordings are compact
+// but not compressed; wrap the io.Writer in a compress/gzip Writer
+// if you want that.
+type Recorder struct {
+	w     io.Writer
+	every int
+	n     int
+
+	lastTime int64
+	nextID   uint64
+	state    map[string]*recState
+	err      error
+}
+
+// NewRecorder returns a Recorder that writes to w, making every
+// keyframeEvery'th frame a keyframe (the first frame is always one).
+// It writes the recording's header immediately.
+func NewRecorder(w io.Writer, keyframeEvery int) (*Recorder, error) {
+	if keyframeEvery < 1 {
+		keyframeEvery = 1
+	}
+	if _, err := io.WriteString(w, recordMagic); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w, every: keyframeEvery}, nil
+}
+
+// unixNano returns t as nanoseconds since the Unix epoch, with the
+// zero time as 0.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano reverses unixNano.
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+func putUvarint(b *bytes.Buffer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func putVarint(b *bytes.Buffer, v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutVarint(buf[:], v)])
+}
+
+func putString(b *bytes.Buffer, s string) {
+	putUvarint(b, uint64(len(s)))
+	b.WriteString(s)
+}
+
+// putValue writes n's value, as a difference from prev if prev is
+// not nil.
+func putValue(b *bytes.Buffer, n, prev *NamedValue) {
+	switch n.Type {
+	case Int32, Int64:
+		if prev != nil {
+			putVarint(b, n.IntVal-prev.IntVal)
+		} else {
+			putVarint(b, n.IntVal)
+		}
+	case Uint32, Uint64:
+		if prev != nil {
+			putVarint(b, int64(n.UintVal-prev.UintVal))
+		} else {
+			putUvarint(b, n.UintVal)
+		}
+	default:
+		putString(b, n.StringVal)
+	}
+}
+
+// sameStats returns true if a and b have the same statistics in the
+// same order.
+func sameStats(a, b []NamedValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes snap to the recording as the next frame. Once a write
+// to the underlying io.Writer has failed, the recording is broken
+// (later frames would be relative to one that may be missing), so
+// Write keeps returning that error.
+func (r *Recorder) Write(snap *Snapshot) error {
+	if r.err != nil {
+		return r.err
+	}
+	var b bytes.Buffer
+	key := r.n%r.every == 0
+	r.n++
+
+	t := unixNano(snap.Time)
+	if key {
+		r.state = make(map[string]*recState)
+		r.nextID = 0
+		b.WriteByte('K')
+		putVarint(&b, t)
+	} else {
+		b.WriteByte('D')
+		putVarint(&b, t-r.lastTime)
+	}
+	r.lastTime = t
+
+	var entries bytes.Buffer
+	nent := 0
+	seen := make(map[string]bool, len(snap.Samples))
+	for _, s := range snap.Samples {
+		k := s.Key()
+		seen[k] = true
+		vals := s.Values()
+		st, ok := r.state[k]
+
+		var flags byte
+		if !ok {
+			st = &recState{id: r.nextID}
+			r.nextID++
+			r.state[k] = st
+			flags |= recNew | recFull
+		} else if !sameStats(st.vals, vals) {
+			flags |= recFull
+		}
+		if s.Raw != nil && (!ok || !bytes.Equal(s.Raw, st.sample.Raw)) {
+			flags |= recRaw
+		}
+		isIO := s.IO != nil
+		if ok && isIO != st.io {
+			flags |= recFull
+		}
+
+		var stats bytes.Buffer
+		if flags&recFull != 0 {
+			putUvarint(&stats, uint64(len(vals)))
+			for i := range vals {
+				putString(&stats, vals[i].Name)
+				stats.WriteByte(byte(vals[i].Type))
+				putValue(&stats, &vals[i], nil)
+			}
+		} else {
+			var changed bytes.Buffer
+			nc := 0
+			for i := range vals {
+				if vals[i] == st.vals[i] {
+					continue
+				}
+				putUvarint(&changed, uint64(i))
+				putValue(&changed, &vals[i], &st.vals[i])
+				nc++
+			}
+			if nc == 0 && flags == 0 && s.Snaptime == st.sample.Snaptime && s.Time.Equal(st.sample.Time) {
+				// Nothing has changed.
+				continue
+			}
+			putUvarint(&stats, uint64(nc))
+			stats.Write(changed.Bytes())
+		}
+
+		if isIO {
+			flags |= recIO
+		}
+		putUvarint(&entries, st.id)
+		entries.WriteByte(flags)
+		if flags&recNew != 0 {
+			putString(&entries, s.Module)
+			putVarint(&entries, int64(s.Instance))
+			putString(&entries, s.Name)
+			putString(&entries, s.Class)
+			entries.WriteByte(byte(s.Type))
+			putVarint(&entries, s.Crtime)
+		}
+		putVarint(&entries, s.Snaptime-st.sample.Snaptime)
+		putVarint(&entries, unixNano(s.Time)-unixNano(st.sample.Time))
+		entries.Write(stats.Bytes())
+		if flags&recRaw != 0 {
+			putString(&entries, string(s.Raw))
+		}
+
+		st.sample = *s
+		st.vals = append([]NamedValue(nil), vals...)
+		st.io = isIO
+		nent++
+	}
+
+	var removed []uint64
+	for k, st := range r.state {
+		if !seen[k] {
+			removed = append(removed, st.id)
+			delete(r.state, k)
+		}
+	}
+	putUvarint(&b, uint64(len(removed)))
+	for _, id := range removed {
+		putUvarint(&b, id)
+	}
+	putUvarint(&b, uint64(nent))
+	b.Write(entries.Bytes())
+
+	if _, err := r.w.Write(b.Bytes()); err != nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// RecordReader reads Snapshots back from a recording made by a
+// Recorder.
+type RecordReader struct {
+	r   *bufio.Reader
+	src *countReader
+	// seeker is the recording's io.Seeker, if it has one.
+	seeker io.Seeker
+	// keys is the keyframes read so far, in order.
+	keys []recKey
+
+	lastTime int64
+	state    map[uint64]*recState
+	err      error
+
+	// At() has to read one frame past the one it returns; it's
+	// kept here for the next Next() or At().
+	peeked *Snapshot
+	// cur is the Snapshot that Next() or At() last returned.
+	cur *Snapshot
+}
+
+// recKey is the time and offset of a keyframe.
+type recKey struct {
+	t   int64
+	off int64
+}
+
+// countReader counts the bytes read through it, so that we know the
+// offsets of frames.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewRecordReader returns a RecordReader for the recording in r,
+// after checking its header. r should be at the start of the
+// recording. If r is also an io.Seeker (as an *os.File is), At() can
+// go backwards.
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	src := &countReader{r: r}
+	br := bufio.NewReader(src)
+	hdr := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(br, hdr); err != nil || string(hdr) != recordMagic {
+		return nil, errors.New("not a kstat recording")
+	}
+	rr := &RecordReader{r: br, src: src}
+	if s, ok := r.(io.Seeker); ok {
+		rr.seeker = s
+	}
+	return rr, nil
+}
+
+var errCorrupt = errors.New("corrupt kstat recording")
+
+// recDecoder reads the parts of a frame, remembering the first
+// error so that callers can check once at the end.
+type recDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *recDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.setErr(err)
+	return v
+}
+
+func (d *recDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.setErr(err)
+	return v
+}
+
+func (d *recDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	c, err := d.r.ReadByte()
+	d.setErr(err)
+	return c
+}
+
+func (d *recDecoder) count() int {
+	n := d.uvarint()
+	if n > maxRecCount {
+		d.setErr(errCorrupt)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *recDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > maxRecString {
+		d.setErr(errCorrupt)
+		return nil
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	d.setErr(err)
+	return b
+}
+
+func (d *recDecoder) string() string {
+	return string(d.bytes())
+}
+
+// setErr records err, turning an EOF in the middle of a frame into
+// io.ErrUnexpectedEOF.
+func (d *recDecoder) setErr(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if d.err == nil && err != nil {
+		d.err = err
+	}
+}
+
+// value reads a value of type t into n, as a difference from n's
+// current value if delta is true.
+func (d *recDecoder) value(n *NamedValue, delta bool) {
+	switch n.Type {
+	case Int32, Int64:
+		v := d.varint()
+		if delta {
+			v += n.IntVal
+		}
+		n.IntVal = v
+	case Uint32, Uint64:
+		if delta {
+			n.UintVal += uint64(d.varint())
+		} else {
+			n.UintVal = d.uvarint()
+		}
+	default:
+		n.StringVal = d.string()
+	}
+}
+
+// ioFromValues reverses ioValues.
+func ioFromValues(vals []NamedValue) *IO {
+	var io IO
+	for _, n := range vals {
+		switch n.Name {
+		case "nread":
+			io.Nread = n.UintVal
+		case "nwritten":
+			io.Nwritten = n.UintVal
+		case "reads":
+			io.Reads = uint32(n.UintVal)
+		case "writes":
+			io.Writes = uint32(n.UintVal)
+		case "wtime":
+			io.Wtime = n.IntVal
+		case "wlentime":
+			io.Wlentime = n.IntVal
+		case "wlastupdate":
+			io.Wlastupdate = n.IntVal
+		case "rtime":
+			io.Rtime = n.IntVal
+		case "rlentime":
+			io.Rlentime = n.IntVal
+		case "rlastupdate":
+			io.Rlastupdate = n.IntVal
+		case "wcnt":
+			io.Wcnt = uint32(n.UintVal)
+		case "rcnt":
+			io.Rcnt = uint32(n.UintVal)
+		}
+	}
+	return &io
+}
+
+// Next reads the next frame and returns the full Snapshot as of it.
+// It returns io.EOF at the end of the recording. The Snapshot is not
+// shared with the RecordReader.
+func (rr *RecordReader) Next() (*Snapshot, error) {
+	if rr.peeked != nil {
+		rr.cur, rr.peeked = rr.peeked, nil
+		return rr.cur, nil
+	}
+	if rr.err != nil {
+		return nil, rr.err
+	}
+	snap, err := rr.next()
+	if err != nil {
+		rr.err = err
+		return nil, err
+	}
+	rr.cur = snap
+	return snap, nil
+}
+
+func (rr *RecordReader) next() (*Snapshot, error) {
+	off := rr.src.n - int64(rr.r.Buffered())
+	kind, err := rr.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	d := &recDecoder{r: rr.r}
+	switch kind {
+	case 'K':
+		rr.state = make(map[uint64]*recState)
+		rr.lastTime = d.varint()
+		if n := len(rr.keys); d.err == nil && (n == 0 || off > rr.keys[n-1].off) {
+			rr.keys = append(rr.keys, recKey{rr.lastTime, off})
+		}
+	case 'D':
+		if rr.state == nil {
+			return nil, errCorrupt
+		}
+		rr.lastTime += d.varint()
+	default:
+		return nil, errCorrupt
+	}
+
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		delete(rr.state, d.uvarint())
+	}
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		id := d.uvarint()
+		flags := d.byte()
+		st, ok := rr.state[id]
+		switch {
+		case flags&recNew != 0:
+			st = &recState{id: id}
+			s := &st.sample
+			s.Module = d.string()
+			s.Instance = int(d.varint())
+			s.Name = d.string()
+			s.Class = d.string()
+			s.Type = KSType(d.byte())
+			s.Crtime = d.varint()
+			rr.state[id] = st
+		case !ok:
+			d.setErr(errCorrupt)
+			continue
+		}
+		st.sample.Snaptime += d.varint()
+		st.sample.Time = fromUnixNano(unixNano(st.sample.Time) + d.varint())
+
+		if flags&recFull != 0 {
+			st.vals = make([]NamedValue, d.count())
+			for i := range st.vals {
+				st.vals[i].Name = d.string()
+				st.vals[i].Type = NamedType(d.byte())
+				d.value(&st.vals[i], false)
+			}
+		} else {
+			for c := d.count(); c > 0 && d.err == nil; c-- {
+				i := d.uvarint()
+				if i >= uint64(len(st.vals)) {
+					d.setErr(errCorrupt)
+					break
+				}
+				d.value(&st.vals[i], true)
+			}
+		}
+		if flags&recRaw != 0 {
+			st.sample.Raw = d.bytes()
+		}
+		st.io = flags&recIO != 0
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("reading kstat recording: %s", d.err)
+	}
+
+	snap := Snapshot{Time: fromUnixNano(rr.lastTime)}
+	for _, st := range rr.state {
+		s := st.sample
+		s.Raw = append([]byte(nil), s.Raw...)
+		// We rebuild whichever form was recorded, whatever
+		// the sample's Type says.
+		if st.io {
+			s.IO = ioFromValues(st.vals)
+		} else {
+			s.Named = append([]NamedValue(nil), st.vals...)
+		}
+		snap.Samples = append(snap.Samples, &s)
+	}
+	sortSamples(snap.Samples)
+	return &snap, nil
+}
+
+// At reads through the recording as far as it needs to and returns
+// the Snapshot of the last frame at or before t, which may be the
+// Snapshot that Next() or At() last returned. If t is before that
+// Snapshot, At seeks back to the last keyframe at or before t and
+// reads forward from there; if the recording can't seek, At returns
+// io.EOF, as it does if t is before the first frame. The frame after
+// t is not lost; it's what the next Next() returns.
+func (rr *RecordReader) At(t time.Time) (*Snapshot, error) {
+	last := rr.cur
+	if last != nil && last.Time.After(t) {
+		if err := rr.seekBack(t); err != nil {
+			return nil, err
+		}
+		last = nil
+	}
+	for {
+		snap, err := rr.Next()
+		if err == io.EOF || (err == nil && snap.Time.After(t)) {
+			if err == nil {
+				rr.peeked = snap
+			}
+			rr.cur = last
+			if last == nil {
+				return nil, io.EOF
+			}
+			return last, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = snap
+	}
+}
+
+// seekBack repositions rr at the last keyframe at or before t that it
+// has read, forgetting everything after it.
+func (rr *RecordReader) seekBack(t time.Time) error {
+	i := sort.Search(len(rr.keys), func(i int) bool {
+		return fromUnixNano(rr.keys[i].t).After(t)
+	})
+	if rr.seeker == nil || i == 0 {
+		return io.EOF
+	}
+	k := rr.keys[i-1]
+	if _, err := rr.seeker.Seek(k.off, io.SeekStart); err != nil {
+		return err
+	}
+	rr.src.n = k.off
+	rr.r.Reset(rr.src)
+	rr.state, rr.err, rr.peeked, rr.cur = nil, nil, nil, nil
+	return nil
+}
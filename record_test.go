@@ -0,0 +1,233 @@
+//
+// Test recording and reading back Snapshots.
+
+package kstat_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// recSnaps makes a series of snapshots that change in various ways.
+func recSnaps() []*kstat.Snapshot {
+	var snaps []*kstat.Snapshot
+	base := time.Unix(1500000000, 0)
+	for i := 0; i < 8; i++ {
+		t := base.Add(time.Duration(i) * time.Second)
+		snaptime := int64(1000+i) * int64(time.Second)
+		state := "on-line"
+		if i >= 5 {
+			state = "off-line"
+		}
+		tcp := &kstat.Sample{Module: "tcp", Name: "tcp", Class: "mib2", Type: kstat.NamedStat,
+			Crtime: 10, Snaptime: snaptime, Time: t,
+			Named: []kstat.NamedValue{
+				{Name: "inSegs", Type: kstat.Uint64, UintVal: 1<<40 + uint64(i)*1000},
+				{Name: "maxConn", Type: kstat.Int32, IntVal: -1},
+				{Name: "state", Type: kstat.String, StringVal: state},
+			}}
+		if i == 6 {
+			// The statistics change.
+			tcp.Named = append(tcp.Named, kstat.NamedValue{Name: "new", Type: kstat.Int64, IntVal: -int64(i)})
+		}
+		sd := &kstat.Sample{Module: "sd", Instance: 2, Name: "sd2", Class: "disk", Type: kstat.IoStat,
+			Crtime: 20, Snaptime: snaptime + 5, Time: t,
+			IO: &kstat.IO{Nread: uint64(i) << 20, Reads: uint32(i), Wtime: int64(i) * 7, Rcnt: 1}}
+		raw := &kstat.Sample{Module: "unix", Name: "var", Type: kstat.RawStat, Snaptime: 500, Time: base,
+			Raw: []byte{1, 2, 3, byte(i / 4)}}
+		// Hand-built samples may not set a Type.
+		cpu := &kstat.Sample{Module: "cpu", Name: "sys", Snaptime: snaptime, Time: t,
+			Named: []kstat.NamedValue{{Name: "cpu_ticks_idle", Type: kstat.Uint64, UintVal: uint64(i) * 100}}}
+		snap := &kstat.Snapshot{Time: t, Samples: []*kstat.Sample{sd, tcp, raw, cpu}}
+		if i >= 2 && i < 4 {
+			// A kstat comes and goes.
+			snap.Samples = append(snap.Samples, &kstat.Sample{Module: "zfs", Name: "objset-0x1", Type: kstat.NamedStat,
+				Snaptime: snaptime, Time: t,
+				Named: []kstat.NamedValue{{Name: "dataset_name", Type: kstat.String, StringVal: "rpool/ROOT"}}})
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	snaps := recSnaps()
+	var b bytes.Buffer
+	r, err := kstat.NewRecorder(&b, 3)
+	if err != nil {
+		t.Fatalf("NewRecorder error: %s", err)
+	}
+	var sizes []int
+	for _, s := range snaps {
+		before := b.Len()
+		if err := r.Write(s); err != nil {
+			t.Fatalf("Write error: %s", err)
+		}
+		sizes = append(sizes, b.Len()-before)
+	}
+	if sizes[1] >= sizes[0] || sizes[4] >= sizes[3] {
+		t.Fatalf("delta frames are not smaller than keyframes: %v", sizes)
+	}
+
+	rr, err := kstat.NewRecordReader(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatalf("NewRecordReader error: %s", err)
+	}
+	for i, exp := range snaps {
+		got, err := rr.Next()
+		if err != nil {
+			t.Fatalf("Next error at frame %d: %s", i, err)
+		}
+		// Sort the expected samples the same way.
+		exp = exp.Select()
+		sorted := []*kstat.Sample{}
+		for _, k := range []string{"cpu", "sd", "tcp", "unix", "zfs"} {
+			for _, s := range exp.Samples {
+				if s.Module == k {
+					sorted = append(sorted, s)
+				}
+			}
+		}
+		exp.Samples = sorted
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("frame %d wrong:\n%+v\nexpected:\n%+v", i, got, exp)
+		}
+	}
+	if _, err := rr.Next(); err != io.EOF {
+		t.Fatalf("no EOF at end: %v", err)
+	}
+}
+
+// snapAt reads the recording in data forward to frame i.
+func snapAt(t *testing.T, data []byte, i int) *kstat.Snapshot {
+	rr, _ := kstat.NewRecordReader(bytes.NewReader(data))
+	var snap *kstat.Snapshot
+	for ; i >= 0; i-- {
+		var err error
+		if snap, err = rr.Next(); err != nil {
+			t.Fatalf("Next error: %s", err)
+		}
+	}
+	return snap
+}
+
+func TestRecordAt(t *testing.T) {
+	snaps := recSnaps()
+	var b bytes.Buffer
+	r, _ := kstat.NewRecorder(&b, 4)
+	for _, s := range snaps {
+		r.Write(s)
+	}
+	data := b.Bytes()
+	rr, _ := kstat.NewRecordReader(bytes.NewReader(data))
+	got, err := rr.At(snaps[5].Time.Add(500 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("At error: %s", err)
+	}
+	if !got.Time.Equal(snaps[5].Time) || got.Lookup("tcp", 0, "tcp").Stat("state").StringVal != "off-line" {
+		t.Fatalf("At returned the wrong snapshot: %+v", got)
+	}
+
+	// Going backwards seeks back to an earlier keyframe, and Next
+	// carries on from there.
+	got, err = rr.At(snaps[2].Time)
+	if err != nil || !got.Time.Equal(snaps[2].Time) || got.Lookup("tcp", 0, "tcp").Stat("state").StringVal != "on-line" {
+		t.Fatalf("At going backwards gave %v, %v", got, err)
+	}
+	if got, err := rr.Next(); err != nil || !got.Time.Equal(snaps[3].Time) {
+		t.Fatalf("Next after going backwards gave %v, %v", got, err)
+	}
+	rr.At(snaps[7].Time)
+	if got, err := rr.At(snaps[6].Time); err != nil || !reflect.DeepEqual(got.Samples, snapAt(t, data, 6).Samples) {
+		t.Fatalf("At going back to a later keyframe gave %v, %v", got, err)
+	}
+	if _, err := rr.At(snaps[0].Time.Add(-time.Second)); err != io.EOF {
+		t.Fatalf("At before the start did not give EOF: %v", err)
+	}
+
+	// Without seeking, a RecordReader can't go backwards.
+	rr, _ = kstat.NewRecordReader(struct{ io.Reader }{bytes.NewReader(data)})
+	rr.At(snaps[5].Time)
+	if _, err := rr.At(snaps[0].Time); err != io.EOF {
+		t.Fatalf("At going backwards without seeking did not give EOF: %v", err)
+	}
+
+	// Successive At()s and Next()s don't lose frames.
+	rr, _ = kstat.NewRecordReader(bytes.NewReader(data))
+	if _, err := rr.At(snaps[0].Time.Add(-time.Second)); err != io.EOF {
+		t.Fatalf("At before the start did not give EOF: %v", err)
+	}
+	for i, want := range []int{1, 1, 2, 3} {
+		got, err := rr.At(snaps[want].Time.Add(time.Duration(i) * time.Millisecond))
+		if err != nil || !got.Time.Equal(snaps[want].Time) {
+			t.Fatalf("At %d gave %v, %v; expected frame %d", i, got, err, want)
+		}
+	}
+	for _, want := range snaps[4:] {
+		got, err := rr.Next()
+		if err != nil || !got.Time.Equal(want.Time) {
+			t.Fatalf("Next after At gave %v, %v; expected %s", got, err, want.Time)
+		}
+	}
+	if _, err := rr.At(snaps[7].Time); err != nil {
+		t.Fatalf("At of the last frame at the end failed: %v", err)
+	}
+	if _, err := rr.Next(); err != io.EOF {
+		t.Fatalf("no EOF at end: %v", err)
+	}
+}
+
+// failWriter fails writes while fail is set.
+type failWriter struct {
+	bytes.Buffer
+	fail bool
+}
+
+var errFail = errors.New("write failed")
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errFail
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestRecordWriteError(t *testing.T) {
+	snaps := recSnaps()
+	var w failWriter
+	r, _ := kstat.NewRecorder(&w, 10)
+	if err := r.Write(snaps[0]); err != nil {
+		t.Fatalf("Write error: %s", err)
+	}
+	w.fail = true
+	if err := r.Write(snaps[1]); err != errFail {
+		t.Fatalf("failed write gave %v", err)
+	}
+	// Later frames would be relative to the lost one, so the
+	// error sticks.
+	w.fail = false
+	n := w.Len()
+	if err := r.Write(snaps[2]); err != errFail || w.Len() != n {
+		t.Fatalf("Write after a failure gave %v and wrote %d bytes", err, w.Len()-n)
+	}
+}
+
+func TestRecordCorrupt(t *testing.T) {
+	if _, err := kstat.NewRecordReader(bytes.NewReader([]byte("garbage\n"))); err == nil {
+		t.Fatalf("NewRecordReader accepted garbage")
+	}
+	var b bytes.Buffer
+	r, _ := kstat.NewRecorder(&b, 10)
+	r.Write(recSnaps()[0])
+	data := b.Bytes()
+	rr, _ := kstat.NewRecordReader(bytes.NewReader(data[:len(data)-3]))
+	if _, err := rr.Next(); err == nil || err == io.EOF {
+		t.Fatalf("truncated recording gave %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+//
+// A registry of decoders for RawStat KStats, so that KStat.Decode()
+// can turn any raw kstat that someone knows about into a Go value.
+// The registry itself doesn't need cgo and so exists everywhere.
+
+package kstat
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// RawDecoder decodes the raw data of a RawStat KStat into a typed
+// value, normally a pointer to a struct. ndata is the KStat's
+// ks_ndata, the same as Raw.Ndata; for most raw kstats it is just
+// len(data), but variable-sized raw kstats often use it as a count
+// of records.
+//
+// data is always a private Go copy of the kstat data, so decoders
+// may hang on to it if they want.
+type RawDecoder func(data []byte, ndata uint64) (interface{}, error)
+
+type rawEntry struct {
+	module, name string
+	size         int
+	dec          RawDecoder
+}
+
+// The registry is consulted from the end backwards, so that later
+// registrations take priority over earlier ones (including our own).
+var (
+	rawLock     sync.RWMutex
+	rawDecoders []rawEntry
+)
+
+// RegisterDecoder registers dec as the decoder for RawStat KStats
+// with a matching module and name. module and name are shell glob
+// patterns, as understood by path.Match(), so eg "nfs" and "mntinfo"
+// or "mm" and "phys_*". size is the exact size of the kstat data in
+// bytes that dec expects; a size of 0 means that dec accepts data of
+// any size and will check it itself.
+//
+// Decoders registered later take priority over ones registered
+// earlier, which lets you override the package's own decoders for
+// things like unix:0:sysinfo if you need to.
+//
+// RegisterDecoder panics if dec is nil or either pattern is malformed.
+func RegisterDecoder(module, name string, size int, dec RawDecoder) {
+	if dec == nil {
+		panic("RegisterDecoder given nil decoder")
+	}
+	if _, err := path.Match(module, ""); err != nil {
+		panic(fmt.Sprintf("RegisterDecoder: bad module pattern %q", module))
+	}
+	if _, err := path.Match(name, ""); err != nil {
+		panic(fmt.Sprintf("RegisterDecoder: bad name pattern %q", name))
+	}
+	if size < 0 {
+		panic("RegisterDecoder given negative size")
+	}
+	rawLock.Lock()
+	rawDecoders = append(rawDecoders, rawEntry{module, name, size, dec})
+	rawLock.Unlock()
+}
+
+// UnregisterDecoder removes the most recent registration made with
+// exactly the patterns module and name, so that whatever it was
+// overriding is used again. It returns false if there was no such
+// registration.
+func UnregisterDecoder(module, name string) bool {
+	rawLock.Lock()
+	defer rawLock.Unlock()
+	for i := len(rawDecoders) - 1; i >= 0; i-- {
+		e := rawDecoders[i]
+		if e.module == module && e.name == name {
+			rawDecoders = append(rawDecoders[:i], rawDecoders[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// LookupDecoder returns the registered decoder for a RawStat with
+// the given module, name, and data size, or nil if there is none.
+func LookupDecoder(module, name string, size int) RawDecoder {
+	rawLock.RLock()
+	defer rawLock.RUnlock()
+	for i := len(rawDecoders) - 1; i >= 0; i-- {
+		e := rawDecoders[i]
+		if e.size != 0 && e.size != size {
+			continue
+		}
+		// Patterns were validated at registration, so we can
+		// ignore errors here.
+		if ok, _ := path.Match(e.module, module); !ok {
+			continue
+		}
+		if ok, _ := path.Match(e.name, name); ok {
+			return e.dec
+		}
+	}
+	return nil
+}
+
+// StructDecoder returns a RawDecoder that copies the raw data into a
+// new value of the struct type that proto points to, returning a
+// pointer to it (so the result has the same type as proto). The
+// struct must meet the same conditions as for CopyTo() and the data
+// must be exactly the same size as it.
+//
+// If you give StructDecoder a bad argument, it panics.
+func StructDecoder(proto interface{}) RawDecoder {
+	t := safeStructPtr(proto, "StructDecoder").Type()
+	return func(data []byte, _ uint64) (interface{}, error) {
+		if uintptr(len(data)) != t.Size() {
+			return nil, fmt.Errorf("data size %d does not match %s size %d", len(data), t, t.Size())
+		}
+		v := reflect.New(t)
+		if len(data) == 0 {
+			return v.Interface(), nil
+		}
+		// This is the same trick as CopyTo, except that the
+		// source is Go memory instead of C memory.
+		v.Elem().Set(reflect.NewAt(t, unsafe.Pointer(&data[0])).Elem())
+		return v.Interface(), nil
+	}
+}
+
+// SliceDecoder returns a RawDecoder for raw kstats that are arrays of
+// fixed-size records. proto is a pointer to a slice of the record
+// type, eg &[]MemUnit{}, and the result is a slice of the same type
+// as *proto. The records are checked and copied as CopyToSlice()
+// does. You normally register SliceDecoders with a size of 0.
+//
+// If you give SliceDecoder a bad argument, it panics.
+func SliceDecoder(proto interface{}) RawDecoder {
+	st := safeSlicePtr(proto, "SliceDecoder").Type()
+	return func(data []byte, ndata uint64) (interface{}, error) {
+		n, err := recordCount(uint64(len(data)), ndata, st.Elem().Size())
+		if err != nil {
+			return nil, err
+		}
+		v := reflect.New(st).Elem()
+		var src unsafe.Pointer
+		if n > 0 {
+			src = unsafe.Pointer(&data[0])
+		}
+		copyRecords(v, src, n)
+		return v.Interface(), nil
+	}
+}
+
+// nativeDecoder returns a RawDecoder that uses NativeLayout to decode
+// module:name.
+func nativeDecoder(module, name string) RawDecoder {
+	return func(data []byte, _ uint64) (interface{}, error) {
+		return NativeLayout.Decode(module, name, data)
+	}
+}
+
+// The raw kstats that we know about ourselves.
+func init() {
+	RegisterDecoder("unix", "sysinfo", SizeofSysinfo, nativeDecoder("unix", "sysinfo"))
+	RegisterDecoder("unix", "vminfo", SizeofVminfo, nativeDecoder("unix", "vminfo"))
+	RegisterDecoder("unix", "var", SizeofVar, nativeDecoder("unix", "var"))
+	RegisterDecoder("nfs", "mntinfo", SizeofMntinfo, nativeDecoder("nfs", "mntinfo"))
+	RegisterDecoder("mm", "phys_installed", 0, SliceDecoder(&[]MemUnit{}))
+	RegisterDecoder("unix", "page_retire_list", 0, SliceDecoder(&[]MemUnit{}))
+}
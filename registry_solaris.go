@@ -0,0 +1,34 @@
+//
+// KStat.Decode(), the cgo side of the raw decoder registry in
+// registry.go.
+
+package kstat
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Decode decodes the data of a RawStat KStat using whatever decoder
+// has been registered for it with RegisterDecoder() and returns the
+// result. For the raw kstats that the package supports directly,
+// this is a pointer to a Sysinfo, Vminfo, Var, or Mntinfo, or a
+// []MemUnit for mm:0:phys_installed and unix:0:page_retire_list. Like
+// Raw(), it does not refresh the KStat's data.
+func (k *KStat) Decode() (interface{}, error) {
+	if k.invalid() {
+		return nil, errors.New("invalid KStat or closed token")
+	}
+	if k.Type != RawStat {
+		return nil, fmt.Errorf("kstat %s (type %s) is not a raw kstat", k, k.Type)
+	}
+	r, err := k.Raw()
+	if err != nil {
+		return nil, err
+	}
+	dec := LookupDecoder(k.Module, k.Name, len(r.Data))
+	if dec == nil {
+		return nil, fmt.Errorf("no decoder for kstat %s with %d bytes of data", k, len(r.Data))
+	}
+	return dec(r.Data, r.Ndata)
+}
@@ -0,0 +1,95 @@
+//
+// Test the raw kstat decoder registry and KStat.Decode().
+
+package kstat_test
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+// Decode() of our built-in raw kstats should give us the same thing
+// as the specific accessors.
+func TestDecode(t *testing.T) {
+	tok := start(t)
+	ks, vi, err := tok.Var()
+	if err != nil {
+		t.Fatalf("Var() error: %s", err)
+	}
+	r, err := ks.Decode()
+	if err != nil {
+		t.Fatalf("%s Decode error: %s", ks, err)
+	}
+	v, ok := r.(*kstat.Var)
+	if !ok {
+		t.Fatalf("%s Decode returned %T, not *kstat.Var", ks, r)
+	}
+	if *v != *vi {
+		t.Fatalf("Var structure difference: Var: %+v Decode: %+v", vi, v)
+	}
+
+	ks = lookup(t, tok, "unix", "sysinfo")
+	r, err = ks.Decode()
+	if err != nil {
+		t.Fatalf("%s Decode error: %s", ks, err)
+	}
+	if si, ok := r.(*kstat.Sysinfo); !ok || si.Updates == 0 {
+		t.Fatalf("%s Decode gave bad result: %#v", ks, r)
+	}
+
+	// Decode only works on raw kstats.
+	ks = lookup(t, tok, "cpu", "sys")
+	r, err = ks.Decode()
+	if err == nil {
+		t.Fatalf("%s Decode succeeded: %#v", ks, r)
+	}
+
+	stop(t, tok)
+
+	_, err = ks.Decode()
+	if err == nil {
+		t.Fatalf("%s Decode succeeded after Close", ks)
+	}
+}
+
+// Registering our own decoder should take priority over the package
+// one, but only if the size matches. The registry is global, so we
+// have to remove our decoders again afterward so that other tests
+// see the package's own decoders.
+func TestRegisterDecoder(t *testing.T) {
+	kstat.RegisterDecoder("unix", "sys*", 1, func(data []byte, ndata uint64) (interface{}, error) {
+		t.Fatalf("decoder with wrong size called")
+		return nil, nil
+	})
+	defer kstat.UnregisterDecoder("unix", "sys*")
+	kstat.RegisterDecoder("un?x", "sys*", int(unsafe.Sizeof(Sysinfo_alt{})), kstat.StructDecoder(&Sysinfo_alt{}))
+	defer kstat.UnregisterDecoder("un?x", "sys*")
+
+	tok := start(t)
+	ks, si, err := tok.Sysinfo()
+	if err != nil {
+		t.Fatalf("Sysinfo() error: %s", err)
+	}
+	r, err := ks.Decode()
+	if err != nil {
+		t.Fatalf("%s Decode error: %s", ks, err)
+	}
+	f, ok := r.(*Sysinfo_alt)
+	if !ok {
+		t.Fatalf("%s Decode returned %T, not *Sysinfo_alt", ks, r)
+	}
+	if si.Updates != f.updates || si.Waiting != f.Waiting {
+		t.Fatalf("%s struct different values: %+v vs %+v", ks, si, f)
+	}
+
+	// Once our override is gone, we get the package's decoder
+	// back.
+	kstat.UnregisterDecoder("un?x", "sys*")
+	r, err = ks.Decode()
+	if _, ok := r.(*kstat.Sysinfo); err != nil || !ok {
+		t.Fatalf("%s Decode after unregister gave %T, %v", ks, r, err)
+	}
+	stop(t, tok)
+}
@@ -0,0 +1,130 @@
+//
+// Test the parts of the raw decoder registry that don't need a
+// kernel.
+
+package kstat_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestLookupDecoder(t *testing.T) {
+	if kstat.LookupDecoder("nfs", "mntinfo", kstat.SizeofMntinfo) == nil {
+		t.Fatalf("no decoder for nfs:mntinfo")
+	}
+	if kstat.LookupDecoder("nfs", "mntinfo", kstat.SizeofMntinfo-4) != nil {
+		t.Fatalf("found nfs:mntinfo decoder for wrong size")
+	}
+	if kstat.LookupDecoder("nosuch", "mntinfo", 0) != nil {
+		t.Fatalf("LookupDecoder found a decoder for nosuch:mntinfo")
+	}
+	// Size 0 registrations accept anything.
+	if kstat.LookupDecoder("mm", "phys_installed", 48) == nil {
+		t.Fatalf("no decoder for mm:phys_installed")
+	}
+
+	kstat.RegisterDecoder("test*", "[ab]", 0, func(data []byte, ndata uint64) (interface{}, error) {
+		return len(data), nil
+	})
+	defer kstat.UnregisterDecoder("test*", "[ab]")
+	dec := kstat.LookupDecoder("testmod", "b", 3)
+	if dec == nil {
+		t.Fatalf("no decoder for testmod:b")
+	}
+	if r, err := dec([]byte("abc"), 3); err != nil || r != 3 {
+		t.Fatalf("testmod:b decoder gave %v, %v", r, err)
+	}
+	if kstat.LookupDecoder("testmod", "c", 3) != nil {
+		t.Fatalf("found decoder for testmod:c")
+	}
+
+	if !kstat.UnregisterDecoder("test*", "[ab]") || kstat.LookupDecoder("testmod", "b", 3) != nil {
+		t.Fatalf("UnregisterDecoder did not remove testmod:b decoder")
+	}
+	if kstat.UnregisterDecoder("test*", "[ab]") {
+		t.Fatalf("UnregisterDecoder removed a decoder twice")
+	}
+}
+
+func TestRegisterDecoderPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("RegisterDecoder with bad pattern did not panic")
+		}
+	}()
+	kstat.RegisterDecoder("[", "x", 0, kstat.StructDecoder(&kstat.Sysinfo{}))
+}
+
+// This has 6 uint32s, just like Sysinfo, but they are in a mixture of
+// unexported fields, embedded structs, and arrays. It doesn't cover
+// all combinations but it does cover a number of them.
+//
+// We pick updates as our unexported field because it's highly likely
+// to be non-zero, just in case. (I am probably worrying too much, and
+// the Swps often seem to be zero, so.)
+type Sysinfo_alt struct {
+	updates uint32
+	Runs    struct {
+		Runque uint32
+		runocc uint32
+	}
+	Swps    [2]uint32
+	Waiting uint32
+}
+
+func TestStructDecoder(t *testing.T) {
+	si := kstat.Sysinfo{Updates: 10, Waiting: 3}
+	dec := kstat.StructDecoder(&Sysinfo_alt{})
+	r, err := dec(encode(t, kstat.NativeLayout.Order, si), kstat.SizeofSysinfo)
+	if err != nil {
+		t.Fatalf("StructDecoder error: %s", err)
+	}
+	f, ok := r.(*Sysinfo_alt)
+	if !ok || f.updates != 10 || f.Waiting != 3 {
+		t.Fatalf("StructDecoder gave bad result: %#v", r)
+	}
+	_, err = dec(make([]byte, 4), 4)
+	if err == nil {
+		t.Fatalf("StructDecoder accepted short data")
+	}
+}
+
+func TestSliceDecoder(t *testing.T) {
+	mu := []kstat.MemUnit{{0x1000, 0x2000}, {0x10000, 0x3000}, {0x100000, 0x4000}}
+	data := encode(t, kstat.NativeLayout.Order, mu)
+	dec := kstat.SliceDecoder(&[]kstat.MemUnit{})
+
+	// As a variable sized kstat, with ndata as a record count.
+	r, err := dec(data, 3)
+	if m, ok := r.([]kstat.MemUnit); err != nil || !ok || len(m) != 3 || m[2] != mu[2] {
+		t.Fatalf("SliceDecoder gave bad result: %v: %#v", err, r)
+	}
+	// As an ordinary raw kstat, with ndata == size.
+	r, err = dec(data, uint64(len(data)))
+	if m, ok := r.([]kstat.MemUnit); err != nil || !ok || len(m) != 3 || m[0] != mu[0] {
+		t.Fatalf("SliceDecoder gave bad result: %v: %#v", err, r)
+	}
+	// Empty.
+	r, err = dec(nil, 0)
+	if m, ok := r.([]kstat.MemUnit); err != nil || !ok || len(m) != 0 {
+		t.Fatalf("SliceDecoder gave bad result for no data: %v: %#v", err, r)
+	}
+	// Wrong record size.
+	_, err = dec(data, 6)
+	if err == nil {
+		t.Fatalf("SliceDecoder accepted wrong record size")
+	}
+	_, err = dec(data[:40], 40)
+	if err == nil {
+		t.Fatalf("SliceDecoder accepted partial records")
+	}
+
+	u := []uint32{1, 2, 3}
+	r, err = kstat.SliceDecoder(&[]uint32{})(encode(t, binary.BigEndian, u), 12)
+	if v, ok := r.([]uint32); err != nil || !ok || len(v) != 3 || (kstat.NativeLayout.Order == binary.BigEndian) != (v[1] == 2) {
+		t.Fatalf("SliceDecoder of uint32 gave bad result: %v: %#v", err, r)
+	}
+}
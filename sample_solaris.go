@@ -0,0 +1,93 @@
+//
+// Taking detached Samples and Snapshots of kstats.
+
+package kstat
+
+import (
+	"syscall"
+	"time"
+)
+
+// Sample refreshes the KStat and returns a detached copy of its
+// current data.
+func (k *KStat) Sample() (*Sample, error) {
+	if err := k.Refresh(); err != nil {
+		return nil, err
+	}
+	s := Sample{
+		Module: k.Module, Instance: k.Instance, Name: k.Name,
+		Class: k.Class, Type: k.Type, Crtime: k.Crtime,
+	}
+
+	switch k.Type {
+	case NamedStat:
+		lst, err := k.AllNamed()
+		if err != nil {
+			return nil, err
+		}
+		s.Named = make([]NamedValue, len(lst))
+		for i, n := range lst {
+			s.Named[i] = NamedValue{Name: n.Name, Type: n.Type,
+				StringVal: n.StringVal, IntVal: n.IntVal, UintVal: n.UintVal}
+		}
+	case IoStat:
+		io, err := k.GetIO()
+		if err != nil {
+			return nil, err
+		}
+		s.IO = io
+	case RawStat:
+		r, err := k.Raw()
+		if err != nil {
+			return nil, err
+		}
+		s.Raw = r.Data
+	}
+	// GetIO() refreshes again, so we get the Snaptime last.
+	s.Snaptime = k.Snaptime
+	s.Time = k.SnapTime()
+	return &s, nil
+}
+
+// selectKStats returns all of the KStats that any of sels matches.
+func (t *Token) selectKStats(sels []Selector) []*KStat {
+	var res []*KStat
+	for _, k := range t.All() {
+		if matchAny(sels, k.Module, k.Instance, k.Name) {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// snapshotOf takes a Snapshot of ks, keeping only the statistics
+// that sels select. KStats that have become invalid or that have
+// disappeared from the kernel since they were looked up are skipped.
+func snapshotOf(ks []*KStat, sels []Selector) (*Snapshot, error) {
+	snap := Snapshot{Time: time.Now()}
+	for _, k := range ks {
+		if !k.Valid() {
+			continue
+		}
+		s, err := k.Sample()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		snap.Samples = append(snap.Samples, s.selectStats(sels))
+	}
+	sortSamples(snap.Samples)
+	return &snap, nil
+}
+
+// Snapshot refreshes every kstat that any of sels selects and returns
+// a detached Snapshot of them, with only the statistics that are
+// selected. With no selectors, it snapshots every kstat.
+//
+// You may want to call Update() first so that the snapshot includes
+// kstats that have been created since the last Update.
+func (t *Token) Snapshot(sels ...Selector) (*Snapshot, error) {
+	return snapshotOf(t.selectKStats(sels), sels)
+}
@@ -0,0 +1,23 @@
+//
+// Timing support for the Sampler.
+
+package kstat
+
+import "time"
+
+// nextTick returns the first time after now that is a multiple of
+// interval since the Unix epoch, so that samplers with the same
+// interval on different machines (or in different processes) take
+// their samples at the same times.
+//
+// (time.Time.Truncate() works relative to Go's zero time, not the
+// Unix epoch, so it's no good for intervals that don't evenly divide
+// the difference between the two, such as 7 seconds.)
+func nextTick(now time.Time, interval time.Duration) time.Time {
+	ns, n := now.UnixNano(), int64(interval)
+	r := ns % n
+	if r < 0 {
+		r += n
+	}
+	return time.Unix(0, ns-r+n)
+}
@@ -0,0 +1,106 @@
+//
+// Sampler periodically takes Snapshots of selected kstats.
+
+package kstat
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// A Sampler periodically takes Snapshots of the kstats (and
+// statistics) picked out by a set of Selectors. Samples are taken at
+// multiples of the interval since the Unix epoch, so that samplers on
+// different machines line up.
+//
+// Before each sample, the Sampler calls Token.Update() and, if the
+// kstat chain has changed, looks up its kstats again, so kstats that
+// appear are picked up and ones that go away are dropped.
+//
+// A running Sampler uses its Token from its own goroutine, so you
+// must not use the Token (or any KStats from it) yourself until the
+// Sampler has stopped.
+type Sampler struct {
+	tok      *Token
+	interval time.Duration
+	sels     []Selector
+
+	ks  []*KStat
+	err error
+}
+
+// NewSampler returns a Sampler that samples the kstats selected by
+// sels through tok every interval. No selectors selects every kstat.
+// It panics if the interval is not positive.
+func NewSampler(tok *Token, interval time.Duration, sels ...Selector) *Sampler {
+	if interval <= 0 {
+		panic("NewSampler given a non-positive interval")
+	}
+	return &Sampler{tok: tok, interval: interval, sels: sels}
+}
+
+// sample takes one Snapshot.
+func (s *Sampler) sample() (*Snapshot, error) {
+	changed, err := s.tok.Update()
+	if err != nil {
+		return nil, err
+	}
+	if changed || s.ks == nil {
+		s.ks = s.tok.selectKStats(s.sels)
+	}
+	return snapshotOf(s.ks, s.sels)
+}
+
+// Run takes a Snapshot every interval and calls fn with it, until ctx
+// is done or fn returns an error. Run returns nil if ctx is done and
+// otherwise returns the error from fn or from sampling.
+func (s *Sampler) Run(ctx context.Context, fn func(*Snapshot) error) error {
+	if s.tok == nil {
+		return errors.New("Sampler has no Token")
+	}
+	timer := time.NewTimer(time.Until(nextTick(time.Now(), s.interval)))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		snap, err := s.sample()
+		if err != nil {
+			return err
+		}
+		if err := fn(snap); err != nil {
+			return err
+		}
+		timer.Reset(time.Until(nextTick(time.Now(), s.interval)))
+	}
+}
+
+// Chan starts sampling in a new goroutine and returns a channel that
+// Snapshots are delivered on. The channel is closed when ctx is done
+// or sampling fails, after which Err() reports any error. If the
+// receiver falls behind, the Sampler waits for it and skips ticks
+// rather than queueing Snapshots.
+func (s *Sampler) Chan(ctx context.Context) <-chan *Snapshot {
+	c := make(chan *Snapshot)
+	go func() {
+		defer close(c)
+		s.err = s.Run(ctx, func(snap *Snapshot) error {
+			select {
+			case c <- snap:
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}()
+	return c
+}
+
+// Err returns the error that stopped a Sampler started with Chan(),
+// or nil. It is only meaningful after the channel has been closed.
+func (s *Sampler) Err() error {
+	return s.err
+}
@@ -0,0 +1,65 @@
+//
+// Test Snapshots and the Sampler.
+
+package kstat_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestSnapshot(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	sl, _ := kstat.ParseSelectors("unix:0:system_misc:ncpus", "unix:0:kstat_types")
+	snap, err := tok.Snapshot(sl...)
+	if err != nil {
+		t.Fatalf("Snapshot error: %s", err)
+	}
+	s := snap.Lookup("unix", 0, "system_misc")
+	if s == nil || len(s.Named) != 1 || s.Stat("ncpus") == nil || s.Snaptime == 0 {
+		t.Fatalf("system_misc sample is wrong: %+v", s)
+	}
+	if s := snap.Lookup("unix", 0, "kstat_types"); s == nil || len(s.Named) == 0 {
+		t.Fatalf("kstat_types sample is wrong: %+v", s)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	sl, _ := kstat.ParseSelectors("unix:0:system_misc")
+	smp := kstat.NewSampler(tok, 100*time.Millisecond, sl...)
+
+	var snaps []*kstat.Snapshot
+	done := errors.New("done")
+	err := smp.Run(context.Background(), func(s *kstat.Snapshot) error {
+		snaps = append(snaps, s)
+		if len(snaps) == 3 {
+			return done
+		}
+		return nil
+	})
+	if err != done {
+		t.Fatalf("Run returned wrong error: %v", err)
+	}
+	for i, s := range snaps {
+		if len(s.Samples) != 1 || (i > 0 && s.Samples[0].Snaptime <= snaps[i-1].Samples[0].Snaptime) {
+			t.Fatalf("sample %d is wrong: %+v", i, s.Samples)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 350*time.Millisecond)
+	defer cancel()
+	n := 0
+	for range smp.Chan(ctx) {
+		n++
+	}
+	if smp.Err() != nil || n < 2 {
+		t.Fatalf("Chan delivered %d snapshots, error %v", n, smp.Err())
+	}
+}
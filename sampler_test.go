@@ -0,0 +1,34 @@
+//
+// Test the Sampler's tick alignment. nextTick isn't exported, so
+// this is an internal test.
+
+package kstat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextTick(t *testing.T) {
+	for _, c := range []struct {
+		now      time.Time
+		interval time.Duration
+		want     int64
+	}{
+		{time.Unix(1700000000, 0), 10 * time.Second, 1700000010},
+		{time.Unix(1700000001, 500), 10 * time.Second, 1700000010},
+		// 7 seconds doesn't divide the offset between Go's
+		// zero time and the Unix epoch.
+		{time.Unix(1700000000, 0), 7 * time.Second, 1700000001},
+		{time.Unix(1700000001, 0), 7 * time.Second, 1700000008},
+		{time.Unix(-5, 0), 7 * time.Second, 0},
+	} {
+		got := nextTick(c.now, c.interval)
+		if got.UnixNano() != c.want*int64(time.Second) {
+			t.Fatalf("nextTick(%d, %s) = %d, want %d", c.now.Unix(), c.interval, got.Unix(), c.want)
+		}
+		if got.Unix()%int64(c.interval/time.Second) != 0 || !got.After(c.now) {
+			t.Fatalf("nextTick(%d, %s) = %d is not a following multiple", c.now.Unix(), c.interval, got.Unix())
+		}
+	}
+}
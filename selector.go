@@ -0,0 +1,98 @@
+//
+// Selectors, which pick out kstats (and optionally statistics in
+// them) by module:instance:name[:statistic] glob patterns.
+
+package kstat
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// A Selector selects kstats, and optionally statistics in them, with
+// path.Match glob patterns for each part of module:instance:name:stat.
+// An empty pattern matches anything. The instance pattern is matched
+// against the instance number in decimal.
+type Selector struct {
+	Module   string
+	Instance string
+	Name     string
+	Stat     string
+}
+
+// ParseSelector parses a selector in the form that kstat(1) uses,
+// "module:instance:name:statistic". Trailing parts may be omitted and
+// any part may be empty, so "zfs::arcstats:hits", "cpu_info", and
+// "::vopstats_*" are all valid.
+func ParseSelector(spec string) (Selector, error) {
+	var s Selector
+	f := strings.Split(spec, ":")
+	if len(f) > 4 {
+		return s, fmt.Errorf("selector %q has too many parts", spec)
+	}
+	parts := []*string{&s.Module, &s.Instance, &s.Name, &s.Stat}
+	for i, p := range f {
+		if _, err := path.Match(p, ""); err != nil {
+			return s, fmt.Errorf("selector %q has bad pattern %q: %s", spec, p, err)
+		}
+		*parts[i] = p
+	}
+	return s, nil
+}
+
+// ParseSelectors parses several selectors with ParseSelector.
+func ParseSelectors(specs ...string) ([]Selector, error) {
+	var res []Selector
+	for _, spec := range specs {
+		s, err := ParseSelector(spec)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (s Selector) String() string {
+	return strings.Join([]string{s.Module, s.Instance, s.Name, s.Stat}, ":")
+}
+
+// globMatch is path.Match where an empty pattern matches anything.
+// Patterns are checked when Selectors are parsed, so errors are
+// treated as not matching.
+func globMatch(pattern, str string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, err := path.Match(pattern, str)
+	return ok && err == nil
+}
+
+// MatchKStat returns true if the selector matches the kstat
+// module:instance:name.
+func (s Selector) MatchKStat(module string, instance int, name string) bool {
+	return globMatch(s.Module, module) && globMatch(s.Name, name) &&
+		globMatch(s.Instance, strconv.Itoa(instance))
+}
+
+// MatchStat returns true if the selector matches the statistic stat
+// in the kstat module:instance:name.
+func (s Selector) MatchStat(module string, instance int, name, stat string) bool {
+	return s.MatchKStat(module, instance, name) && globMatch(s.Stat, stat)
+}
+
+// matchAny returns true if any of sels matches the kstat
+// module:instance:name. No selectors match everything.
+func matchAny(sels []Selector, module string, instance int, name string) bool {
+	if len(sels) == 0 {
+		return true
+	}
+	for _, s := range sels {
+		if s.MatchKStat(module, instance, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+//
+// Test Selectors and selecting from Snapshots.
+
+package kstat_test
+
+import (
+	"testing"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestParseSelector(t *testing.T) {
+	for _, c := range []struct {
+		spec string
+		sel  kstat.Selector
+	}{
+		{"zfs::arcstats:hits", kstat.Selector{Module: "zfs", Name: "arcstats", Stat: "hits"}},
+		{"cpu_info", kstat.Selector{Module: "cpu_info"}},
+		{"::vopstats_*", kstat.Selector{Name: "vopstats_*"}},
+		{"cpu:1[0-9]:sys", kstat.Selector{Module: "cpu", Instance: "1[0-9]", Name: "sys"}},
+		{"", kstat.Selector{}},
+	} {
+		s, err := kstat.ParseSelector(c.spec)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q) error: %s", c.spec, err)
+		}
+		if s != c.sel {
+			t.Fatalf("ParseSelector(%q) is %+v, expected %+v", c.spec, s, c.sel)
+		}
+	}
+	for _, bad := range []string{"a:b:c:d:e", "zfs:[:arcstats"} {
+		if _, err := kstat.ParseSelector(bad); err == nil {
+			t.Fatalf("ParseSelector(%q) did not fail", bad)
+		}
+	}
+	if s, _ := kstat.ParseSelector("zfs:0"); s.String() != "zfs:0::" {
+		t.Fatalf("wrong String: %q", s.String())
+	}
+}
+
+func TestSelectorMatch(t *testing.T) {
+	sl, err := kstat.ParseSelectors("cpu:1[0-9]:sys", "zfs::arcstats:*hits")
+	if err != nil {
+		t.Fatalf("ParseSelectors error: %s", err)
+	}
+	if !sl[0].MatchKStat("cpu", 12, "sys") || sl[0].MatchKStat("cpu", 1, "sys") || sl[0].MatchKStat("cpu", 12, "vm") {
+		t.Fatalf("wrong MatchKStat results for %s", sl[0])
+	}
+	if !sl[1].MatchStat("zfs", 0, "arcstats", "mru_hits") || sl[1].MatchStat("zfs", 0, "arcstats", "misses") {
+		t.Fatalf("wrong MatchStat results for %s", sl[1])
+	}
+}
+
+func TestSnapshotSelect(t *testing.T) {
+	snap := &kstat.Snapshot{Samples: []*kstat.Sample{
+		{Module: "cpu", Instance: 0, Name: "sys", Named: []kstat.NamedValue{{Name: "cpu_ticks_idle"}, {Name: "syscall"}}},
+		{Module: "zfs", Instance: 0, Name: "arcstats", Named: []kstat.NamedValue{{Name: "hits"}, {Name: "misses"}, {Name: "size"}}},
+		{Module: "sd", Instance: 0, Name: "sd0", IO: &kstat.IO{Reads: 10}},
+	}}
+	sl, _ := kstat.ParseSelectors("zfs::arcstats:hits", "zfs::arcstats:misses", "sd")
+	ns := snap.Select(sl...)
+	if len(ns.Samples) != 2 {
+		t.Fatalf("wrong samples selected: %+v", ns.Samples)
+	}
+	a := ns.Lookup("zfs", 0, "arcstats")
+	if a == nil || len(a.Named) != 2 || a.Stat("hits") == nil || a.Stat("size") != nil {
+		t.Fatalf("wrong arcstats statistics selected: %+v", a)
+	}
+	if len(snap.Samples[1].Named) != 3 {
+		t.Fatalf("Select modified the original snapshot")
+	}
+	if d := ns.Lookup("sd", 0, "sd0"); d == nil || d.IO.Reads != 10 || d.Key() != "sd:0:sd0" {
+		t.Fatalf("wrong IO sample selected: %+v", d)
+	}
+	if ns := snap.Select(); len(ns.Samples) != 3 {
+		t.Fatalf("Select with no selectors did not select everything")
+	}
+}
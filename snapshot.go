@@ -0,0 +1,161 @@
+//
+// Snapshots: detached copies of kstat data that remain usable after
+// their Token is closed and that can be used on any platform.
+
+package kstat
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// NamedValue is a detached copy of a Named statistic's value.
+type NamedValue struct {
+	Name string    `json:"name"`
+	Type NamedType `json:"type"`
+
+	// As in Named, only one of these is valid.
+	StringVal string `json:"string,omitempty"`
+	IntVal    int64  `json:"int,omitempty"`
+	UintVal   uint64 `json:"uint,omitempty"`
+}
+
+// Sample is a detached copy of a kstat's data at some point. Named
+// kstats have their statistics in Named, IO kstats have theirs in IO,
+// and raw kstats have their raw bytes in Raw. Other sorts of kstats
+// only have the identifying information.
+type Sample struct {
+	Module   string `json:"module"`
+	Instance int    `json:"instance"`
+	Name     string `json:"name"`
+	Class    string `json:"class"`
+	Type     KSType `json:"type"`
+	Crtime   int64  `json:"crtime"`
+	Snaptime int64  `json:"snaptime"`
+
+	// Time is the wall clock time of Snaptime.
+	Time time.Time `json:"time"`
+
+	Named []NamedValue `json:"named,omitempty"`
+	IO    *IO          `json:"io,omitempty"`
+	Raw   []byte       `json:"raw,omitempty"`
+}
+
+// Key returns the sample's kstat as "module:instance:name".
+func (s *Sample) Key() string {
+	return fmt.Sprintf("%s:%d:%s", s.Module, s.Instance, s.Name)
+}
+
+// Stat returns the named statistic called name, or nil if there is
+// no such statistic.
+func (s *Sample) Stat(name string) *NamedValue {
+	for i := range s.Named {
+		if s.Named[i].Name == name {
+			return &s.Named[i]
+		}
+	}
+	return nil
+}
+
+// Number returns the value of a numeric statistic as a float64, or
+// false if the statistic is a string.
+func (n *NamedValue) Number() (float64, bool) {
+	switch n.Type {
+	case Int32, Int64:
+		return float64(n.IntVal), true
+	case Uint32, Uint64:
+		return float64(n.UintVal), true
+	}
+	return 0, false
+}
+
+// ioValues returns the fields of io as NamedValues, using the names
+// that kstat(1) gives them.
+func ioValues(io *IO) []NamedValue {
+	u := func(name string, v uint64) NamedValue { return NamedValue{Name: name, Type: Uint64, UintVal: v} }
+	i := func(name string, v int64) NamedValue { return NamedValue{Name: name, Type: Int64, IntVal: v} }
+	return []NamedValue{
+		u("nread", io.Nread), u("nwritten", io.Nwritten),
+		u("reads", uint64(io.Reads)), u("writes", uint64(io.Writes)),
+		i("wtime", io.Wtime), i("wlentime", io.Wlentime), i("wlastupdate", io.Wlastupdate),
+		i("rtime", io.Rtime), i("rlentime", io.Rlentime), i("rlastupdate", io.Rlastupdate),
+		u("wcnt", uint64(io.Wcnt)), u("rcnt", uint64(io.Rcnt)),
+	}
+}
+
+// Values returns the sample's statistics as NamedValues. For named
+// kstats this is Named; for IO kstats, it's the fields of IO under
+// the names that kstat(1) uses for them (nread, wlentime, etc). Other
+// sorts of kstats have no values.
+func (s *Sample) Values() []NamedValue {
+	if s.IO != nil {
+		return ioValues(s.IO)
+	}
+	return s.Named
+}
+
+// selectStats returns a copy of s with only the named statistics
+// that one of sels matches. No selectors select everything.
+func (s *Sample) selectStats(sels []Selector) *Sample {
+	if len(sels) == 0 || s.Named == nil {
+		return s
+	}
+	ns := *s
+	ns.Named = nil
+	for _, n := range s.Named {
+		for _, sel := range sels {
+			if sel.MatchStat(s.Module, s.Instance, s.Name, n.Name) {
+				ns.Named = append(ns.Named, n)
+				break
+			}
+		}
+	}
+	return &ns
+}
+
+// A Snapshot is a set of Samples taken at about the same time, sorted
+// by module, instance, and name.
+type Snapshot struct {
+	// Time is when the snapshot was started.
+	Time    time.Time `json:"time"`
+	Samples []*Sample `json:"samples"`
+}
+
+// sortSamples sorts samples by module, instance, and name.
+func sortSamples(sl []*Sample) {
+	sort.Slice(sl, func(i, j int) bool {
+		a, b := sl[i], sl[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.Instance != b.Instance {
+			return a.Instance < b.Instance
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Lookup returns the sample for module:instance:name, or nil if
+// there isn't one.
+func (s *Snapshot) Lookup(module string, instance int, name string) *Sample {
+	for _, smp := range s.Samples {
+		if smp.Module == module && smp.Instance == instance && smp.Name == name {
+			return smp
+		}
+	}
+	return nil
+}
+
+// Select returns a new Snapshot with only the samples and named
+// statistics that are selected by any of sels. The samples are
+// shared with s unless some of their statistics are dropped.
+func (s *Snapshot) Select(sels ...Selector) *Snapshot {
+	ns := Snapshot{Time: s.Time}
+	for _, smp := range s.Samples {
+		if matchAny(sels, smp.Module, smp.Instance, smp.Name) {
+			ns.Samples = append(ns.Samples, smp.selectStats(sels))
+		}
+	}
+	return &ns
+}
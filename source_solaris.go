@@ -0,0 +1,15 @@
+//
+// Tokens as Sources.
+
+package kstat
+
+// Headers returns Samples with the identifying information of every
+// kstat, without reading their data.
+func (t *Token) Headers() ([]*Sample, error) {
+	var res []*Sample
+	for _, k := range t.All() {
+		res = append(res, &Sample{Module: k.Module, Instance: k.Instance, Name: k.Name,
+			Class: k.Class, Type: k.Type, Crtime: k.Crtime, Snaptime: k.Snaptime})
+	}
+	return res, nil
+}
@@ -0,0 +1,166 @@
+//
+// Shared support for the typed statistics structs (ArcStats and so
+// on) that are filled in from named kstats, and for computing rates
+// from two of them.
+
+package kstat
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// statTag returns the kstat statistic name and any options from a
+// struct field's `kstat:"name,opt,..."` tag. The name is "" if the
+// field has no tag.
+func statTag(f reflect.StructField) (string, []string) {
+	tag := f.Tag.Get("kstat")
+	if tag == "" || tag == "-" {
+		return "", nil
+	}
+	l := strings.Split(tag, ",")
+	return l[0], l[1:]
+}
+
+// counterDelta returns how much a counter bits wide (32 or 64) has
+// gone up from prev to cur. If a 32-bit counter goes backwards, it
+// wrapped around. A 64-bit counter will never wrap in practice, so if
+// one goes backwards it was reset (or its kstat was deleted and
+// recreated) and we take it as having counted up from zero, ie the
+// delta is cur.
+func counterDelta(prev, cur uint64, bits int) uint64 {
+	switch {
+	case cur >= prev:
+		return cur - prev
+	case bits == 32:
+		return uint64(uint32(cur - prev))
+	default:
+		return cur
+	}
+}
+
+// snapInterval returns the interval between two Snaptimes.
+func snapInterval(prev, cur int64) time.Duration {
+	return time.Duration(cur - prev)
+}
+
+// perSecond returns the per-second rate of a counter bits wide that
+// went from prev to cur over the interval d. A non-positive interval
+// gives a rate of zero, not a division by zero.
+func perSecond(prev, cur uint64, bits int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(counterDelta(prev, cur, bits)) / d.Seconds()
+}
+
+// pct returns part as a percentage of total, or 0 if total is 0.
+func pct(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * part / total
+}
+
+// niceNum formats n in a short human-readable form using binary
+// K/M/G/T/P/E suffixes, the way illumos's nicenum() does for fsstat
+// and similar commands: '923', '1.21K', '45.6M', '512G'.
+func niceNum(n float64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%.0f", n)
+	}
+	i := -1
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	switch {
+	case n < 10:
+		return fmt.Sprintf("%.2f%c", n, units[i])
+	case n < 100:
+		return fmt.Sprintf("%.1f%c", n, units[i])
+	default:
+		return fmt.Sprintf("%.0f%c", n, units[i])
+	}
+}
+
+// StatRates computes per-second rates between two samples of the same
+// typed statistics struct, such as two *TCPStats, which must have a
+// Snaptime field tagged `kstat:"snaptime"`. The result maps each
+// tagged integer field's statistic name to its rate over the interval
+// between the samples, except that fields tagged as gauges or states
+// (with a `kstat:"name,gauge"` or `kstat:"name,state"` tag) map to
+// their current value instead, since they are not counters.
+//
+// StatRates panics if prev and cur are not pointers to the same sort
+// of struct or if the struct has no Snaptime.
+func StatRates(prev, cur interface{}) map[string]float64 {
+	pv, cv := reflect.ValueOf(prev), reflect.ValueOf(cur)
+	if cv.Kind() != reflect.Ptr || cv.Elem().Kind() != reflect.Struct || pv.Type() != cv.Type() {
+		panic("StatRates not given two pointers to the same type of struct")
+	}
+	pv, cv = pv.Elem(), cv.Elem()
+	vt := cv.Type()
+
+	snap := -1
+	for i := 0; i < vt.NumField(); i++ {
+		if name, _ := statTag(vt.Field(i)); name == "snaptime" {
+			snap = i
+		}
+	}
+	if snap == -1 {
+		panic(fmt.Sprintf("StatRates: %s has no snaptime field", vt))
+	}
+	d := snapInterval(pv.Field(snap).Int(), cv.Field(snap).Int())
+
+	res := make(map[string]float64)
+	for i := 0; i < vt.NumField(); i++ {
+		name, opts := statTag(vt.Field(i))
+		if name == "" || i == snap {
+			continue
+		}
+		level := hasOpt(opts, "gauge") || hasOpt(opts, "state")
+		var p, c uint64
+		bits := 64
+		if hasOpt(opts, "uint32") {
+			bits = 32
+		}
+		switch cv.Field(i).Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			bits = 32
+			fallthrough
+		case reflect.Uint, reflect.Uint64:
+			p, c = pv.Field(i).Uint(), cv.Field(i).Uint()
+		case reflect.Int8, reflect.Int16, reflect.Int32:
+			bits = 32
+			fallthrough
+		case reflect.Int, reflect.Int64:
+			if level {
+				res[name] = float64(cv.Field(i).Int())
+				continue
+			}
+			p, c = uint64(pv.Field(i).Int()), uint64(cv.Field(i).Int())
+		default:
+			continue
+		}
+		if level {
+			res[name] = float64(c)
+		} else {
+			res[name] = perSecond(p, c, bits, d)
+		}
+	}
+	return res
+}
+
+// hasOpt returns true if a field's tag options include opt.
+func hasOpt(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
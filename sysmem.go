@@ -0,0 +1,101 @@
+//
+// Typed access to the system-wide memory and miscellaneous
+// statistics in unix:0:system_pages and unix:0:system_misc.
+
+package kstat
+
+import "time"
+
+// FScale is the scale of the fixed point load averages in kstats
+// such as unix:0:system_misc's avenrun_1min. It's the kernel's
+// FSCALE.
+const FScale = 1 << 8
+
+// LoadAvg converts a FSCALE fixed point load average to a float.
+func LoadAvg(avenrun uint32) float64 {
+	return float64(avenrun) / FScale
+}
+
+// SystemPages is the system memory statistics from
+// unix:0:system_pages. Everything is in pages; PageSize is the size
+// of a page in bytes and is filled in when the statistics are
+// retrieved (it is not a statistic).
+type SystemPages struct {
+	Snaptime int64 `kstat:"snaptime"`
+	PageSize uint64
+
+	Physmem     uint64 `kstat:"physmem,gauge"`
+	Freemem     uint64 `kstat:"freemem,gauge"`
+	Availrmem   uint64 `kstat:"availrmem,gauge"`
+	PPKernel    uint64 `kstat:"pp_kernel,gauge"`
+	Pagestotal  uint64 `kstat:"pagestotal,gauge"`
+	Pagesfree   uint64 `kstat:"pagesfree,gauge"`
+	Pageslocked uint64 `kstat:"pageslocked,gauge"`
+
+	// The page scanner's thresholds and state.
+	Lotsfree uint64 `kstat:"lotsfree,gauge"`
+	Desfree  uint64 `kstat:"desfree,gauge"`
+	Minfree  uint64 `kstat:"minfree,gauge"`
+	Fastscan uint64 `kstat:"fastscan,gauge"`
+	Slowscan uint64 `kstat:"slowscan,gauge"`
+	Nscan    uint64 `kstat:"nscan,gauge"`
+	Desscan  uint64 `kstat:"desscan,gauge"`
+}
+
+// Bytes converts a count of pages to bytes.
+func (p *SystemPages) Bytes(pages uint64) uint64 {
+	return pages * p.PageSize
+}
+
+// PhysmemBytes returns the amount of physical memory in bytes.
+func (p *SystemPages) PhysmemBytes() uint64 {
+	return p.Bytes(p.Physmem)
+}
+
+// FreememBytes returns the amount of free memory in bytes.
+func (p *SystemPages) FreememBytes() uint64 {
+	return p.Bytes(p.Freemem)
+}
+
+// AvailrmemBytes returns the amount of memory that is available to
+// be locked down, in bytes.
+func (p *SystemPages) AvailrmemBytes() uint64 {
+	return p.Bytes(p.Availrmem)
+}
+
+// KernelBytes returns the amount of memory used by the kernel, in
+// bytes.
+func (p *SystemPages) KernelBytes() uint64 {
+	return p.Bytes(p.PPKernel)
+}
+
+// SystemMisc is the miscellaneous system statistics from
+// unix:0:system_misc.
+type SystemMisc struct {
+	Snaptime int64 `kstat:"snaptime"`
+
+	Ncpus       uint64 `kstat:"ncpus,gauge"`
+	Nproc       uint64 `kstat:"nproc,gauge"`
+	BootTime    int64  `kstat:"boot_time,gauge"`
+	Lbolt       uint64 `kstat:"lbolt"`
+	ClkIntr     uint64 `kstat:"clk_intr"`
+	NsecPerTick uint64 `kstat:"nsec_per_tick,gauge"`
+	Deficit     int64  `kstat:"deficit,gauge"`
+	Vac         uint64 `kstat:"vac,gauge"`
+
+	// The load averages are FSCALE fixed point numbers; see
+	// LoadAvg() and the LoadAvg() method.
+	Avenrun1Min  uint32 `kstat:"avenrun_1min,gauge"`
+	Avenrun5Min  uint32 `kstat:"avenrun_5min,gauge"`
+	Avenrun15Min uint32 `kstat:"avenrun_15min,gauge"`
+}
+
+// LoadAvg returns the 1, 5, and 15 minute load averages.
+func (m *SystemMisc) LoadAvg() [3]float64 {
+	return [3]float64{LoadAvg(m.Avenrun1Min), LoadAvg(m.Avenrun5Min), LoadAvg(m.Avenrun15Min)}
+}
+
+// Boot returns when the system booted.
+func (m *SystemMisc) Boot() time.Time {
+	return time.Unix(m.BootTime, 0)
+}
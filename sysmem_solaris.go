@@ -0,0 +1,27 @@
+//
+// Retrieving SystemPages and SystemMisc.
+
+package kstat
+
+import "os"
+
+// SystemPages returns the current system memory statistics from
+// unix:0:system_pages, with PageSize set to the system's page size.
+func (t *Token) SystemPages() (*SystemPages, error) {
+	p := SystemPages{}
+	if err := t.lookupFill("unix", 0, "system_pages", &p); err != nil {
+		return nil, err
+	}
+	p.PageSize = uint64(os.Getpagesize())
+	return &p, nil
+}
+
+// SystemMisc returns the current miscellaneous system statistics
+// from unix:0:system_misc.
+func (t *Token) SystemMisc() (*SystemMisc, error) {
+	m := SystemMisc{}
+	if err := t.lookupFill("unix", 0, "system_misc", &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,28 @@
+//
+// Test retrieving SystemPages and SystemMisc.
+
+package kstat_test
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSystemMemory(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	p, err := tok.SystemPages()
+	if err != nil {
+		t.Fatalf("SystemPages error: %s", err)
+	}
+	if p.PageSize == 0 || p.Physmem == 0 || p.Freemem > p.Physmem {
+		t.Fatalf("system pages are odd: %+v", p)
+	}
+	m, err := tok.SystemMisc()
+	if err != nil {
+		t.Fatalf("SystemMisc error: %s", err)
+	}
+	if m.Ncpus == 0 || m.Boot().After(time.Now()) {
+		t.Fatalf("system misc stats are odd: %+v", m)
+	}
+}
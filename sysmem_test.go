@@ -0,0 +1,32 @@
+//
+// Test SystemPages and SystemMisc conversions.
+
+package kstat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestSystemPages(t *testing.T) {
+	p := kstat.SystemPages{PageSize: 4096, Physmem: 1 << 20, Freemem: 1000, Availrmem: 3, PPKernel: 2}
+	if p.PhysmemBytes() != 4<<30 || p.FreememBytes() != 4096000 ||
+		p.AvailrmemBytes() != 3*4096 || p.KernelBytes() != 2*4096 {
+		t.Fatalf("wrong byte conversions: %+v", p)
+	}
+}
+
+func TestSystemMisc(t *testing.T) {
+	if kstat.LoadAvg(384) != 1.5 {
+		t.Fatalf("LoadAvg(384) is %f", kstat.LoadAvg(384))
+	}
+	m := kstat.SystemMisc{BootTime: 1500000000, Avenrun1Min: 256, Avenrun5Min: 64, Avenrun15Min: 0}
+	if la := m.LoadAvg(); la != [3]float64{1, 0.25, 0} {
+		t.Fatalf("wrong load averages: %v", la)
+	}
+	if !m.Boot().Equal(time.Unix(1500000000, 0)) {
+		t.Fatalf("wrong boot time: %s", m.Boot())
+	}
+}
@@ -0,0 +1,141 @@
+//
+// Typed access to the kernel taskq statistics (the named kstats of
+// class taskq and taskq_d), plus a report of the busiest taskqs over
+// an interval.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TaskqStats is the statistics of a kernel taskq. Regular taskqs are
+// class taskq and dynamic ones are class taskq_d; dynamic taskqs
+// count the tasks run by their buckets separately, in the B* fields.
+// Times are in nanoseconds.
+type TaskqStats struct {
+	Module   string `kstat:"module"`
+	Instance int    `kstat:"instance"`
+	Name     string `kstat:"name"`
+	Class    string `kstat:"class"`
+	Snaptime int64  `kstat:"snaptime"`
+
+	Pid        uint64 `kstat:"pid"`
+	Pri        uint64 `kstat:"pri"`
+	Spl        uint64 `kstat:"spl"`
+	Nthreads   uint64 `kstat:"nthreads"`
+	Maxthreads uint64 `kstat:"maxthreads"`
+	Tasks      uint64 `kstat:"tasks"`
+	Executed   uint64 `kstat:"executed"`
+	Maxtasks   uint64 `kstat:"maxtasks"`
+	Totaltime  uint64 `kstat:"totaltime"`
+	Nalloc     uint64 `kstat:"nalloc"`
+	Nfree      uint64 `kstat:"nfree"`
+	Nactive    uint64 `kstat:"nactive"`
+
+	BTasks     uint64 `kstat:"btasks"`
+	BExecuted  uint64 `kstat:"bexecuted"`
+	BMaxtasks  uint64 `kstat:"bmaxtasks"`
+	BTotaltime uint64 `kstat:"btotaltime"`
+}
+
+// TotalExecuted returns how many tasks the taskq has executed,
+// including ones executed by its buckets.
+func (t *TaskqStats) TotalExecuted() uint64 {
+	return t.Executed + t.BExecuted
+}
+
+// TotalTime returns how much time the taskq has spent executing
+// tasks, including time spent by its buckets.
+func (t *TaskqStats) TotalTime() time.Duration {
+	return time.Duration(t.Totaltime + t.BTotaltime)
+}
+
+// TaskqRates is a taskq's activity over an interval.
+type TaskqRates struct {
+	Name     string
+	Instance int
+	Class    string
+	Interval time.Duration
+
+	// Executed is tasks executed per second. Busy is the time
+	// spent executing tasks divided by the interval, which is the
+	// average number of threads that were busy; it can be more
+	// than 1 for multi-threaded taskqs.
+	Executed float64
+	Busy     float64
+}
+
+// RatesSince computes t's activity over the interval between prev
+// and t. prev should be for the same taskq.
+func (t *TaskqStats) RatesSince(prev *TaskqStats) *TaskqRates {
+	d := snapInterval(prev.Snaptime, t.Snaptime)
+	r := TaskqRates{Name: t.Name, Instance: t.Instance, Class: t.Class, Interval: d}
+	r.Executed = perSecond(prev.TotalExecuted(), t.TotalExecuted(), 64, d)
+	if d > 0 {
+		r.Busy = float64(counterDelta(uint64(prev.TotalTime()), uint64(t.TotalTime()), 64)) / float64(d)
+	}
+	return &r
+}
+
+// taskqKey is how taskqs are matched up between samples.
+func (t *TaskqStats) taskqKey() string {
+	return fmt.Sprintf("%s:%d:%s:%s", t.Module, t.Instance, t.Name, t.Class)
+}
+
+// TaskqRatesSince computes the activity of every taskq that is
+// present in both prev and cur, returning them ranked from busiest to
+// least busy. If byTime is true they are ranked by Busy, otherwise by
+// Executed.
+func TaskqRatesSince(prev, cur []*TaskqStats, byTime bool) []*TaskqRates {
+	pm := make(map[string]*TaskqStats, len(prev))
+	for _, t := range prev {
+		pm[t.taskqKey()] = t
+	}
+	var res []*TaskqRates
+	for _, t := range cur {
+		if p, ok := pm[t.taskqKey()]; ok {
+			res = append(res, t.RatesSince(p))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if byTime && a.Busy != b.Busy {
+			return a.Busy > b.Busy
+		}
+		if a.Executed != b.Executed {
+			return a.Executed > b.Executed
+		}
+		if a.Busy != b.Busy {
+			return a.Busy > b.Busy
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Instance < b.Instance
+	})
+	return res
+}
+
+// WriteTaskqTop writes the first n of rates (or all of them if n is
+// not positive) to w as a table. Taskqs that did nothing during the
+// interval are left out.
+func WriteTaskqTop(w io.Writer, rates []*TaskqRates, n int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-32s %4s %-7s %10s %7s\n", "TASKQ", "INST", "CLASS", "EXEC/s", "BUSY")
+	for i, r := range rates {
+		if n > 0 && i >= n {
+			break
+		}
+		if r.Executed == 0 && r.Busy == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%-32s %4d %-7s %10.1f %7.3f\n", r.Name, r.Instance, r.Class, r.Executed, r.Busy)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
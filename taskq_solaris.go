@@ -0,0 +1,35 @@
+//
+// Retrieving TaskqStats.
+
+package kstat
+
+import "syscall"
+
+// Taskqs returns the current statistics of every kernel taskq, both
+// regular and dynamic, in no particular order. Each kstat is
+// refreshed as it's read.
+//
+// Taskqs come and go (for example as pools are imported), so you
+// may want to call Update() first. Taskqs whose kstats have
+// disappeared since then are skipped.
+func (t *Token) Taskqs() ([]*TaskqStats, error) {
+	var res []*TaskqStats
+	for _, k := range t.All() {
+		if k.Type != NamedStat || (k.Class != "taskq" && k.Class != "taskq_d") {
+			continue
+		}
+		err := k.Refresh()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tq := TaskqStats{}
+		if err := k.fill(&tq); err != nil {
+			return nil, err
+		}
+		res = append(res, &tq)
+	}
+	return res, nil
+}
@@ -0,0 +1,26 @@
+//
+// Test retrieving TaskqStats.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+// Every system has a bunch of taskqs.
+func TestTaskqs(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	tl, err := tok.Taskqs()
+	if err != nil {
+		t.Fatalf("Taskqs error: %s", err)
+	}
+	if len(tl) == 0 {
+		t.Fatalf("no taskq kstats found")
+	}
+	for _, tq := range tl {
+		if tq.Name == "" || tq.Snaptime == 0 || (tq.Class != "taskq" && tq.Class != "taskq_d") {
+			t.Fatalf("taskq stats are odd: %+v", tq)
+		}
+	}
+}
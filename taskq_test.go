@@ -0,0 +1,49 @@
+//
+// Test taskq rates and the busiest-taskq report.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestTaskqRates(t *testing.T) {
+	sec := int64(time.Second)
+	prev := []*kstat.TaskqStats{
+		{Name: "zio_write_issue", Class: "taskq", Snaptime: sec, Executed: 100, Totaltime: 0},
+		{Name: "callout_taskq", Class: "taskq_d", Snaptime: sec, BExecuted: 10},
+		{Name: "idle", Class: "taskq", Snaptime: sec},
+	}
+	cur := []*kstat.TaskqStats{
+		{Name: "callout_taskq", Class: "taskq_d", Snaptime: 3 * sec, BExecuted: 210, Executed: 200, BTotaltime: uint64(sec / 2)},
+		{Name: "zio_write_issue", Class: "taskq", Snaptime: 3 * sec, Executed: 300, Totaltime: uint64(3 * sec)},
+		{Name: "idle", Class: "taskq", Snaptime: 3 * sec},
+		{Name: "new", Class: "taskq", Snaptime: 3 * sec, Executed: 1000},
+	}
+	rl := kstat.TaskqRatesSince(prev, cur, false)
+	if len(rl) != 3 || rl[0].Name != "callout_taskq" || rl[1].Name != "zio_write_issue" {
+		t.Fatalf("wrong ranking by executed: %+v", rl)
+	}
+	if r := rl[0]; r.Executed != 200 || !near(r.Busy, 0.25) {
+		t.Fatalf("bad callout_taskq rates: %+v", r)
+	}
+	rl = kstat.TaskqRatesSince(prev, cur, true)
+	if rl[0].Name != "zio_write_issue" || !near(rl[0].Busy, 1.5) {
+		t.Fatalf("wrong ranking by time: %+v", rl)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WriteTaskqTop(&b, rl, 0); err != nil {
+		t.Fatalf("WriteTaskqTop error: %s", err)
+	}
+	exp := "TASKQ                            INST CLASS       EXEC/s    BUSY\n" +
+		"zio_write_issue                     0 taskq        100.0   1.500\n" +
+		"callout_taskq                       0 taskq_d      200.0   0.250\n"
+	if b.String() != exp {
+		t.Fatalf("WriteTaskqTop output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
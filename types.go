@@ -6,11 +6,88 @@
 // because these structs are not exactly likely to change any time
 // soon; that would break API compatibility.
 //
-// This is specific to amd64. It's unlikely that Go will support
-// 32-bit Solaris ('386'), but.
+// None of these structs contain pointers or longs, so they have the
+// same layout on every platform that illumos and Solaris run on (see
+// layout.go for the details). That lets this file build everywhere,
+// along with the other types and constants that don't need cgo, so
+// that code which merely passes them around doesn't need build tags.
 
 package kstat
 
+import "fmt"
+
+// KSType is the type of the data in a KStat.
+type KSType int
+
+// The different types of data that a KStat may contain, ie these
+// are the value of a KStat.Type. We currently only support getting
+// Named and IO statistics.
+const (
+	RawStat   KSType = 0 // KSTAT_TYPE_RAW
+	NamedStat KSType = 1 // KSTAT_TYPE_NAMED
+	IntrStat  KSType = 2 // KSTAT_TYPE_INTR
+	IoStat    KSType = 3 // KSTAT_TYPE_IO
+	TimerStat KSType = 4 // KSTAT_TYPE_TIMER
+)
+
+func (tp KSType) String() string {
+	switch tp {
+	case RawStat:
+		return "raw"
+	case NamedStat:
+		return "named"
+	case IntrStat:
+		return "interrupt"
+	case IoStat:
+		return "io"
+	case TimerStat:
+		return "timer"
+	default:
+		return fmt.Sprintf("kstat_type:%d", tp)
+	}
+}
+
+// NamedType represents the various types of named kstat statistics.
+type NamedType int
+
+// The different types of data that a named kstat statistic can be
+// (ie, these are the potential values of Named.Type).
+const (
+	CharData NamedType = 0 // KSTAT_DATA_CHAR
+	Int32    NamedType = 1 // KSTAT_DATA_INT32
+	Uint32   NamedType = 2 // KSTAT_DATA_UINT32
+	Int64    NamedType = 3 // KSTAT_DATA_INT64
+	Uint64   NamedType = 4 // KSTAT_DATA_UINT64
+	String   NamedType = 9 // KSTAT_DATA_STRING
+
+	// CharData is found in StringVal. At the moment we assume that
+	// it is a real string, because this matches how it seems to be
+	// used for short strings in the Solaris kernel. Someday we may
+	// find something that uses it as just a data dump for 16 bytes.
+
+	// Solaris sys/kstat.h also has _FLOAT (5) and _DOUBLE (6) types,
+	// but labels them as obsolete.
+)
+
+func (tp NamedType) String() string {
+	switch tp {
+	case CharData:
+		return "char"
+	case Int32:
+		return "int32"
+	case Uint32:
+		return "uint32"
+	case Int64:
+		return "int64"
+	case Uint64:
+		return "uint64"
+	case String:
+		return "string"
+	default:
+		return fmt.Sprintf("named_type-%d", tp)
+	}
+}
+
 // IO represents the entire collection of KStat (disk) IO statistics
 // exposed by an IoStat type KStat.
 //
@@ -101,6 +178,15 @@ type Mntinfo struct {
 	pad0       [3]byte
 }
 
+// MemUnit is one record of the variable-sized raw kstats
+// mm:0:phys_installed and unix:0:page_retire_list, which are arrays
+// of 'struct memunit'. Use KStat.CopyToSlice() or KStat.Decode() to
+// get all of them.
+type MemUnit struct {
+	Address uint64
+	Size    uint64
+}
+
 // CFieldString converts a (null-terminated) C string embedded in an
 // []int8 slice to a (Go) string. The []int8 slice is likely to come
 // from an [N]int8 fixed-size field in a statistics struct. If there
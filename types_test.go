@@ -88,3 +88,14 @@ func TestCFieldString(t *testing.T) {
 		t.Fatalf("embedded null not properly handled: %q", r)
 	}
 }
+
+// The type constants have fixed values so that they work everywhere;
+// check a few of them and their names.
+func TestTypeNames(t *testing.T) {
+	if kstat.IoStat != 3 || kstat.IoStat.String() != "io" || kstat.KSType(7).String() != "kstat_type:7" {
+		t.Fatalf("KSType values or names are wrong")
+	}
+	if kstat.String != 9 || kstat.String.String() != "string" || kstat.Uint64.String() != "uint64" {
+		t.Fatalf("NamedType values or names are wrong")
+	}
+}
@@ -0,0 +1,178 @@
+//
+// Typed access to the vnode operation statistics in the
+// unix:0:vopstats_* kstats, plus an fsstat-style report of their
+// rates.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VopStats is the vnode operation counts for either a filesystem
+// type (from eg unix:0:vopstats_zfs) or a single mounted filesystem
+// (from unix:0:vopstats_<fsid>, where fsid is in hex). Everything is
+// a counter; the N* fields count operations and the *Bytes fields
+// count bytes.
+type VopStats struct {
+	Name     string `kstat:"name"`
+	Snaptime int64  `kstat:"snaptime"`
+
+	Nopen        uint64 `kstat:"nopen"`
+	Nclose       uint64 `kstat:"nclose"`
+	Nread        uint64 `kstat:"nread"`
+	ReadBytes    uint64 `kstat:"read_bytes"`
+	Nwrite       uint64 `kstat:"nwrite"`
+	WriteBytes   uint64 `kstat:"write_bytes"`
+	Nioctl       uint64 `kstat:"nioctl"`
+	Nsetfl       uint64 `kstat:"nsetfl"`
+	Ngetattr     uint64 `kstat:"ngetattr"`
+	Nsetattr     uint64 `kstat:"nsetattr"`
+	Naccess      uint64 `kstat:"naccess"`
+	Nlookup      uint64 `kstat:"nlookup"`
+	Ncreate      uint64 `kstat:"ncreate"`
+	Nremove      uint64 `kstat:"nremove"`
+	Nlink        uint64 `kstat:"nlink"`
+	Nrename      uint64 `kstat:"nrename"`
+	Nmkdir       uint64 `kstat:"nmkdir"`
+	Nrmdir       uint64 `kstat:"nrmdir"`
+	Nreaddir     uint64 `kstat:"nreaddir"`
+	ReaddirBytes uint64 `kstat:"readdir_bytes"`
+	Nsymlink     uint64 `kstat:"nsymlink"`
+	Nreadlink    uint64 `kstat:"nreadlink"`
+	Nfsync       uint64 `kstat:"nfsync"`
+	Ninactive    uint64 `kstat:"ninactive"`
+	Nfid         uint64 `kstat:"nfid"`
+	Nrwlock      uint64 `kstat:"nrwlock"`
+	Nrwunlock    uint64 `kstat:"nrwunlock"`
+	Nseek        uint64 `kstat:"nseek"`
+	Ncmp         uint64 `kstat:"ncmp"`
+	Nfrlock      uint64 `kstat:"nfrlock"`
+	Nspace       uint64 `kstat:"nspace"`
+	Nrealvp      uint64 `kstat:"nrealvp"`
+	Ngetpage     uint64 `kstat:"ngetpage"`
+	Nputpage     uint64 `kstat:"nputpage"`
+	Nmap         uint64 `kstat:"nmap"`
+	Naddmap      uint64 `kstat:"naddmap"`
+	Ndelmap      uint64 `kstat:"ndelmap"`
+	Npoll        uint64 `kstat:"npoll"`
+	Ndump        uint64 `kstat:"ndump"`
+	Npathconf    uint64 `kstat:"npathconf"`
+	Npageio      uint64 `kstat:"npageio"`
+	Ndumpctl     uint64 `kstat:"ndumpctl"`
+	Ndispose     uint64 `kstat:"ndispose"`
+	Nsetsecattr  uint64 `kstat:"nsetsecattr"`
+	Ngetsecattr  uint64 `kstat:"ngetsecattr"`
+	Nshrlock     uint64 `kstat:"nshrlock"`
+	Nvnevent     uint64 `kstat:"nvnevent"`
+	Nreqzcbuf    uint64 `kstat:"nreqzcbuf"`
+	Nretzcbuf    uint64 `kstat:"nretzcbuf"`
+}
+
+// Target returns what the statistics are for, which is either a
+// filesystem type ("zfs") or a hex fsid ("4b50002").
+func (v *VopStats) Target() string {
+	return strings.TrimPrefix(v.Name, "vopstats_")
+}
+
+// Fsid returns the fsid of the mounted filesystem that v is for, or
+// false if v is for a filesystem type instead. Fsids are told apart
+// from filesystem types by being hex numbers, which means that the
+// fd filesystem type needs special handling.
+func (v *VopStats) Fsid() (uint64, bool) {
+	t := v.Target()
+	if t == "fd" {
+		return 0, false
+	}
+	fsid, err := strconv.ParseUint(t, 16, 64)
+	return fsid, err == nil
+}
+
+// VopRates is the per-second rates of a VopStats over an interval,
+// grouped into the same categories that fsstat uses.
+type VopRates struct {
+	Target   string
+	Interval time.Duration
+
+	NewFile    float64 // create, mkdir, and symlink
+	NameRemove float64 // remove and rmdir
+	NameChange float64 // rename and link
+	AttrGet    float64 // getattr, access, getsecattr, and fid
+	AttrSet    float64 // setattr and setsecattr
+	Lookup     float64
+	Readdir    float64
+	Read       float64
+	ReadBytes  float64
+	Write      float64
+	WriteBytes float64
+}
+
+// RatesSince computes v's rates over the interval between prev and
+// v. prev should be for the same filesystem type or mount.
+func (v *VopStats) RatesSince(prev *VopStats) *VopRates {
+	d := snapInterval(prev.Snaptime, v.Snaptime)
+	rate := func(p, c uint64) float64 { return perSecond(p, c, 64, d) }
+	return &VopRates{
+		Target:     v.Target(),
+		Interval:   d,
+		NewFile:    rate(prev.Ncreate, v.Ncreate) + rate(prev.Nmkdir, v.Nmkdir) + rate(prev.Nsymlink, v.Nsymlink),
+		NameRemove: rate(prev.Nremove, v.Nremove) + rate(prev.Nrmdir, v.Nrmdir),
+		NameChange: rate(prev.Nrename, v.Nrename) + rate(prev.Nlink, v.Nlink),
+		AttrGet: rate(prev.Ngetattr, v.Ngetattr) + rate(prev.Naccess, v.Naccess) +
+			rate(prev.Ngetsecattr, v.Ngetsecattr) + rate(prev.Nfid, v.Nfid),
+		AttrSet:    rate(prev.Nsetattr, v.Nsetattr) + rate(prev.Nsetsecattr, v.Nsetsecattr),
+		Lookup:     rate(prev.Nlookup, v.Nlookup),
+		Readdir:    rate(prev.Nreaddir, v.Nreaddir),
+		Read:       rate(prev.Nread, v.Nread),
+		ReadBytes:  rate(prev.ReadBytes, v.ReadBytes),
+		Write:      rate(prev.Nwrite, v.Nwrite),
+		WriteBytes: rate(prev.WriteBytes, v.WriteBytes),
+	}
+}
+
+// VopRatesSince computes the rates of every filesystem type or mount
+// that is present in both prev and cur, returning them sorted by
+// target with filesystem types before mounts.
+func VopRatesSince(prev, cur []*VopStats) []*VopRates {
+	pm := make(map[string]*VopStats, len(prev))
+	for _, v := range prev {
+		pm[v.Name] = v
+	}
+	var res []*VopRates
+	mount := make(map[string]bool)
+	for _, v := range cur {
+		if p, ok := pm[v.Name]; ok {
+			res = append(res, v.RatesSince(p))
+			_, mount[v.Target()] = v.Fsid()
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		mi, mj := mount[res[i].Target], mount[res[j].Target]
+		if mi != mj {
+			return mj
+		}
+		return res[i].Target < res[j].Target
+	})
+	return res
+}
+
+// WriteFsstat writes rates to w in the format of fsstat.
+func WriteFsstat(w io.Writer, rates []*VopRates) error {
+	var b strings.Builder
+	b.WriteString(" new  name  name  attr  attr lookup rddir  read  read write write\n")
+	b.WriteString(" file remov  chng   get   set    ops   ops   ops bytes   ops bytes\n")
+	for _, r := range rates {
+		fmt.Fprintf(&b, "%5s %5s %5s %5s %5s %6s %5s %5s %5s %5s %5s %s\n",
+			niceNum(r.NewFile), niceNum(r.NameRemove), niceNum(r.NameChange),
+			niceNum(r.AttrGet), niceNum(r.AttrSet), niceNum(r.Lookup),
+			niceNum(r.Readdir), niceNum(r.Read), niceNum(r.ReadBytes),
+			niceNum(r.Write), niceNum(r.WriteBytes), r.Target)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,38 @@
+//
+// Retrieving VopStats.
+
+package kstat
+
+import (
+	"strings"
+	"syscall"
+)
+
+// VopStats returns the current vnode operation statistics for every
+// filesystem type and every mounted filesystem that has them, in no
+// particular order. Each kstat is refreshed as it's read.
+//
+// Per-mount kstats come and go as filesystems are mounted and
+// unmounted, so you may want to call Update() first. Kstats that
+// have disappeared since then are skipped.
+func (t *Token) VopStats() ([]*VopStats, error) {
+	var res []*VopStats
+	for _, k := range t.All() {
+		if k.Type != NamedStat || k.Module != "unix" || !strings.HasPrefix(k.Name, "vopstats_") {
+			continue
+		}
+		err := k.Refresh()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		v := VopStats{}
+		if err := k.fill(&v); err != nil {
+			return nil, err
+		}
+		res = append(res, &v)
+	}
+	return res, nil
+}
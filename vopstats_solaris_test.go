@@ -0,0 +1,26 @@
+//
+// Test retrieving VopStats.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+// Every system has at least some filesystem types with vopstats.
+func TestVopStats(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	vl, err := tok.VopStats()
+	if err != nil {
+		t.Fatalf("VopStats error: %s", err)
+	}
+	if len(vl) == 0 {
+		t.Fatalf("no vopstats kstats found")
+	}
+	for _, v := range vl {
+		if v.Target() == "" || v.Snaptime == 0 {
+			t.Fatalf("vopstats are odd: %+v", v)
+		}
+	}
+}
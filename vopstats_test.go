@@ -0,0 +1,63 @@
+//
+// Test vopstats rates and the fsstat report.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestVopFsid(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		fsid  uint64
+		mount bool
+	}{
+		{"vopstats_zfs", 0, false},
+		{"vopstats_fd", 0, false},
+		{"vopstats_4b50002", 0x4b50002, true},
+	} {
+		v := kstat.VopStats{Name: c.name}
+		fsid, ok := v.Fsid()
+		if fsid != c.fsid || ok != c.mount {
+			t.Fatalf("%s: Fsid() is %x %v, expected %x %v", c.name, fsid, ok, c.fsid, c.mount)
+		}
+	}
+}
+
+func TestWriteFsstat(t *testing.T) {
+	sec := int64(time.Second)
+	prev := []*kstat.VopStats{
+		{Name: "vopstats_zfs", Snaptime: sec},
+		{Name: "vopstats_4b50002", Snaptime: sec},
+		{Name: "vopstats_tmpfs", Snaptime: sec},
+	}
+	cur := []*kstat.VopStats{
+		{Name: "vopstats_4b50002", Snaptime: 2 * sec, Nread: 10, ReadBytes: 10 << 10},
+		{Name: "vopstats_zfs", Snaptime: 2 * sec, Ncreate: 2, Nmkdir: 1, Ngetattr: 100, Naccess: 92, Nlookup: 588, Nwrite: 3, WriteBytes: 1500},
+		{Name: "vopstats_ufs", Snaptime: 2 * sec},
+	}
+	rl := kstat.VopRatesSince(prev, cur)
+	if len(rl) != 2 || rl[0].Target != "zfs" || rl[1].Target != "4b50002" {
+		t.Fatalf("wrong targets in rates: %+v", rl)
+	}
+	if r := rl[0]; r.Interval != time.Second || r.NewFile != 3 || r.AttrGet != 192 {
+		t.Fatalf("bad zfs rates: %+v", r)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WriteFsstat(&b, rl); err != nil {
+		t.Fatalf("WriteFsstat error: %s", err)
+	}
+	exp := " new  name  name  attr  attr lookup rddir  read  read write write\n" +
+		" file remov  chng   get   set    ops   ops   ops bytes   ops bytes\n" +
+		"    3     0     0   192     0    588     0     0     0     3 1.46K zfs\n" +
+		"    0     0     0     0     0      0     0    10 10.0K     0     0 4b50002\n"
+	if b.String() != exp {
+		t.Fatalf("WriteFsstat output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}
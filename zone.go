@@ -0,0 +1,224 @@
+//
+// Typed per-zone resource statistics, gathered from the zones,
+// memory_cap, caps, zone_vfs, and zone_zfs named kstats, plus a
+// zonestat-like summary of them over an interval.
+
+package kstat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ZoneMisc is the general per-zone statistics from a zones:<id>:<name>
+// kstat, mostly CPU usage.
+type ZoneMisc struct {
+	Snaptime   int64  `kstat:"snaptime"`
+	NsecUser   uint64 `kstat:"nsec_user"`
+	NsecSys    uint64 `kstat:"nsec_sys"`
+	NsecWaitrq uint64 `kstat:"nsec_waitrq"`
+	// The load averages are FSCALE fixed point numbers.
+	Avenrun1Min  uint32 `kstat:"avenrun_1min,gauge"`
+	Avenrun5Min  uint32 `kstat:"avenrun_5min,gauge"`
+	Avenrun15Min uint32 `kstat:"avenrun_15min,gauge"`
+	ForkFailCap  uint64 `kstat:"forkfail_cap"`
+	BootTime     int64  `kstat:"boot_time,gauge"`
+}
+
+// ZoneMemCap is the memory capping statistics for a zone from its
+// memory_cap:<id>:<name> kstat. Sizes are in bytes; a cap of zero
+// or the maximum uint64 means that there is no cap.
+type ZoneMemCap struct {
+	Snaptime int64  `kstat:"snaptime"`
+	RSS      uint64 `kstat:"rss,gauge"`
+	PhysCap  uint64 `kstat:"physcap,gauge"`
+	Swap     uint64 `kstat:"swap,gauge"`
+	SwapCap  uint64 `kstat:"swapcap,gauge"`
+	// NOver is how many times the zone has gone over its
+	// physical memory cap and PagedOut is how many bytes have
+	// been paged out as a result.
+	NOver    uint64 `kstat:"nover"`
+	PagedOut uint64 `kstat:"pagedout"`
+	PgpgIn   uint64 `kstat:"pgpgin"`
+	AnonPgIn uint64 `kstat:"anonpgin"`
+}
+
+// ZoneCPUCap is the CPU capping statistics for a zone from its
+// caps:<id>:cpucaps_zone_<id> kstat. Usage and Value (the cap) are in
+// percent of a single CPU, so a cap of 200 is two CPUs.
+type ZoneCPUCap struct {
+	Snaptime int64  `kstat:"snaptime"`
+	Value    uint64 `kstat:"value,gauge"`
+	Usage    uint64 `kstat:"usage,gauge"`
+	MaxUsage uint64 `kstat:"maxusage,gauge"`
+	// AboveSec and BelowSec are how many seconds the zone has
+	// spent above and below its cap, and Nwait is the number of
+	// threads currently waiting because of the cap.
+	AboveSec uint64 `kstat:"above_sec"`
+	BelowSec uint64 `kstat:"below_sec"`
+	Nwait    uint64 `kstat:"nwait,gauge"`
+}
+
+// ZoneVFS is a zone's VFS layer I/O statistics from its
+// zone_vfs:<id>:<name> kstat, including counts of operations that
+// took at least 10ms, 100ms, 1s, and 10s. Times are in nanoseconds.
+type ZoneVFS struct {
+	Snaptime  int64  `kstat:"snaptime"`
+	Nread     uint64 `kstat:"nread"`
+	Reads     uint64 `kstat:"reads"`
+	Rtime     uint64 `kstat:"rtime"`
+	Rlentime  uint64 `kstat:"rlentime"`
+	Nwritten  uint64 `kstat:"nwritten"`
+	Writes    uint64 `kstat:"writes"`
+	Wtime     uint64 `kstat:"wtime"`
+	Wlentime  uint64 `kstat:"wlentime"`
+	Ops10ms   uint64 `kstat:"10ms_ops"`
+	Ops100ms  uint64 `kstat:"100ms_ops"`
+	Ops1s     uint64 `kstat:"1s_ops"`
+	Ops10s    uint64 `kstat:"10s_ops"`
+	DelayCnt  uint64 `kstat:"delay_cnt"`
+	DelayTime uint64 `kstat:"delay_time"`
+}
+
+// ZoneZFS is a zone's ZFS I/O statistics from its zone_zfs:<id>:<name>
+// kstat.
+type ZoneZFS struct {
+	Snaptime int64  `kstat:"snaptime"`
+	Nread    uint64 `kstat:"nread"`
+	Reads    uint64 `kstat:"reads"`
+	Rtime    uint64 `kstat:"rtime"`
+	Rlentime uint64 `kstat:"rlentime"`
+	Nwritten uint64 `kstat:"nwritten"`
+	Writes   uint64 `kstat:"writes"`
+	WaitTime uint64 `kstat:"waittime"`
+}
+
+// ZoneStats is all of the statistics for a zone. Any of the parts
+// may be nil if the zone (or the system) doesn't have the kstat for
+// it; for example, zones without a CPU cap have no caps kstat.
+type ZoneStats struct {
+	Zonename string
+	Zoneid   int
+
+	Misc   *ZoneMisc
+	MemCap *ZoneMemCap
+	CPUCap *ZoneCPUCap
+	VFS    *ZoneVFS
+	ZFS    *ZoneZFS
+}
+
+// ZoneSummary is a zonestat-like summary of a zone's resource usage
+// over an interval.
+type ZoneSummary struct {
+	Zonename string
+	Interval time.Duration
+
+	// CPUPct is the zone's CPU usage (user plus system) as a
+	// percentage of the whole machine, and CapPct is its usage as
+	// a percentage of its CPU cap (zero if it has no cap).
+	CPUPct float64
+	CapPct float64
+
+	// Memory usage, as in ZoneMemCap, and RSS and swap as
+	// percentages of their caps (zero if there is no cap).
+	RSS      uint64
+	PhysCap  uint64
+	MemPct   float64
+	Swap     uint64
+	SwapCap  uint64
+	SwapPct  float64
+	NOver    uint64 // during the interval
+	PagedOut uint64 // during the interval
+
+	// Per-second VFS and ZFS read and write bytes.
+	VFSRead    float64
+	VFSWritten float64
+	ZFSRead    float64
+	ZFSWritten float64
+}
+
+// capped returns true if a memory cap is actually set.
+func capped(c uint64) bool {
+	return c != 0 && c != ^uint64(0)
+}
+
+// summarize computes z's ZoneSummary since prev, on a machine with
+// ncpus CPUs.
+func (z *ZoneStats) summarize(prev *ZoneStats, ncpus int) *ZoneSummary {
+	s := ZoneSummary{Zonename: z.Zonename}
+	if z.Misc != nil && prev.Misc != nil {
+		s.Interval = snapInterval(prev.Misc.Snaptime, z.Misc.Snaptime)
+		busy := counterDelta(prev.Misc.NsecUser, z.Misc.NsecUser, 64) + counterDelta(prev.Misc.NsecSys, z.Misc.NsecSys, 64)
+		if ncpus > 0 {
+			s.CPUPct = pct(float64(busy), float64(s.Interval)*float64(ncpus))
+		}
+	}
+	if z.CPUCap != nil && z.CPUCap.Value != 0 {
+		s.CapPct = pct(float64(z.CPUCap.Usage), float64(z.CPUCap.Value))
+	}
+	if m := z.MemCap; m != nil {
+		s.RSS, s.PhysCap, s.Swap, s.SwapCap = m.RSS, m.PhysCap, m.Swap, m.SwapCap
+		if capped(m.PhysCap) {
+			s.MemPct = pct(float64(m.RSS), float64(m.PhysCap))
+		}
+		if capped(m.SwapCap) {
+			s.SwapPct = pct(float64(m.Swap), float64(m.SwapCap))
+		}
+		if p := prev.MemCap; p != nil {
+			s.NOver = counterDelta(p.NOver, m.NOver, 64)
+			s.PagedOut = counterDelta(p.PagedOut, m.PagedOut, 64)
+		}
+	}
+	if v, p := z.VFS, prev.VFS; v != nil && p != nil {
+		d := snapInterval(p.Snaptime, v.Snaptime)
+		s.VFSRead = perSecond(p.Nread, v.Nread, 64, d)
+		s.VFSWritten = perSecond(p.Nwritten, v.Nwritten, 64, d)
+	}
+	if f, p := z.ZFS, prev.ZFS; f != nil && p != nil {
+		d := snapInterval(p.Snaptime, f.Snaptime)
+		s.ZFSRead = perSecond(p.Nread, f.Nread, 64, d)
+		s.ZFSWritten = perSecond(p.Nwritten, f.Nwritten, 64, d)
+	}
+	return &s
+}
+
+// ZoneSummaries summarizes the resource usage of every zone present
+// in both prev and cur (which map zone names to their ZoneStats) on a
+// machine with ncpus CPUs, sorted by zone name.
+func ZoneSummaries(prev, cur map[string]*ZoneStats, ncpus int) []*ZoneSummary {
+	var res []*ZoneSummary
+	for name, z := range cur {
+		if p, ok := prev[name]; ok {
+			res = append(res, z.summarize(p, ncpus))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Zonename < res[j].Zonename })
+	return res
+}
+
+// capStr formats a memory cap for WriteZonestat.
+func capStr(c uint64) string {
+	if !capped(c) {
+		return "-"
+	}
+	return niceNum(float64(c))
+}
+
+// WriteZonestat writes zone summaries to w as a zonestat-like table.
+func WriteZonestat(w io.Writer, sums []*ZoneSummary) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-16s %6s %6s %7s %7s %6s %7s %7s %6s %7s %7s %7s %7s\n",
+		"ZONE", "CPU%", "CAP%", "RSS", "PHYSCAP", "MEM%", "SWAP", "SWAPCAP", "NOVER",
+		"VFSRD", "VFSWR", "ZFSRD", "ZFSWR")
+	for _, s := range sums {
+		fmt.Fprintf(&b, "%-16s %6.2f %6.2f %7s %7s %6.2f %7s %7s %6d %7s %7s %7s %7s\n",
+			s.Zonename, s.CPUPct, s.CapPct, niceNum(float64(s.RSS)), capStr(s.PhysCap),
+			s.MemPct, niceNum(float64(s.Swap)), capStr(s.SwapCap), s.NOver,
+			niceNum(s.VFSRead), niceNum(s.VFSWritten), niceNum(s.ZFSRead), niceNum(s.ZFSWritten))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,82 @@
+//
+// Retrieving ZoneStats.
+
+package kstat
+
+import (
+	"strings"
+	"syscall"
+)
+
+// Zones returns the current statistics for every zone, keyed by zone
+// name. They are gathered from the zones, memory_cap, caps, zone_vfs,
+// and zone_zfs kstats; each kstat is refreshed as it's read.
+//
+// The kstats involved are per zone and not all of them necessarily
+// exist, so a zone is included if any of them do. Kstats that have
+// disappeared since the last Update() (for example because their
+// zone was halted) are skipped.
+func (t *Token) Zones() (map[string]*ZoneStats, error) {
+	res := make(map[string]*ZoneStats)
+	for _, k := range t.All() {
+		if k.Type != NamedStat {
+			continue
+		}
+		var part interface{}
+		switch {
+		case k.Module == "zones":
+			part = &ZoneMisc{}
+		case k.Module == "memory_cap":
+			part = &ZoneMemCap{}
+		case k.Module == "caps" && strings.HasPrefix(k.Name, "cpucaps_zone_"):
+			part = &ZoneCPUCap{}
+		case k.Module == "zone_vfs":
+			part = &ZoneVFS{}
+		case k.Module == "zone_zfs":
+			part = &ZoneZFS{}
+		default:
+			continue
+		}
+
+		err := k.Refresh()
+		if err == syscall.ENXIO {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := k.fill(part); err != nil {
+			return nil, err
+		}
+		// The kstat name is often a truncated zone name, so we
+		// use the zonename statistic.
+		var zn struct {
+			Zonename string `kstat:"zonename"`
+		}
+		if err := k.fill(&zn); err != nil {
+			return nil, err
+		}
+		if zn.Zonename == "" {
+			continue
+		}
+		z, ok := res[zn.Zonename]
+		if !ok {
+			z = &ZoneStats{Zonename: zn.Zonename, Zoneid: k.Instance}
+			res[zn.Zonename] = z
+		}
+
+		switch p := part.(type) {
+		case *ZoneMisc:
+			z.Misc = p
+		case *ZoneMemCap:
+			z.MemCap = p
+		case *ZoneCPUCap:
+			z.CPUCap = p
+		case *ZoneVFS:
+			z.VFS = p
+		case *ZoneZFS:
+			z.ZFS = p
+		}
+	}
+	return res, nil
+}
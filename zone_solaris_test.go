@@ -0,0 +1,29 @@
+//
+// Test retrieving ZoneStats.
+
+package kstat_test
+
+import (
+	"testing"
+)
+
+// Every machine has at least the global zone, although older systems
+// may not have the per-zone kstats.
+func TestZones(t *testing.T) {
+	tok := start(t)
+	defer stop(t, tok)
+	zm, err := tok.Zones()
+	if err != nil {
+		t.Fatalf("Zones error: %s", err)
+	}
+	z, ok := zm["global"]
+	if !ok {
+		t.Skip("skipping test due to no global zone kstats")
+	}
+	if z.Zoneid != 0 {
+		t.Fatalf("global zone has wrong zoneid: %+v", z)
+	}
+	if z.Misc != nil && z.Misc.Snaptime == 0 {
+		t.Fatalf("global zone misc stats are odd: %+v", z.Misc)
+	}
+}
@@ -0,0 +1,59 @@
+//
+// Test zone summaries and zonestat-style formatting.
+
+package kstat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/siebenmann/go-kstat"
+)
+
+func TestZoneSummaries(t *testing.T) {
+	sec := int64(time.Second)
+	prev := map[string]*kstat.ZoneStats{
+		"web": {
+			Zonename: "web", Zoneid: 2,
+			Misc:   &kstat.ZoneMisc{Snaptime: sec, NsecUser: 0, NsecSys: 0},
+			MemCap: &kstat.ZoneMemCap{Snaptime: sec, NOver: 1, PagedOut: 4096},
+			VFS:    &kstat.ZoneVFS{Snaptime: sec, Nread: 0},
+		},
+		"gone": {Zonename: "gone"},
+	}
+	cur := map[string]*kstat.ZoneStats{
+		"web": {
+			Zonename: "web", Zoneid: 2,
+			Misc:   &kstat.ZoneMisc{Snaptime: 3 * sec, NsecUser: uint64(sec), NsecSys: uint64(sec)},
+			MemCap: &kstat.ZoneMemCap{Snaptime: 3 * sec, RSS: 512 << 20, PhysCap: 1 << 30, Swap: 1 << 20, SwapCap: ^uint64(0), NOver: 3, PagedOut: 3 * 4096},
+			CPUCap: &kstat.ZoneCPUCap{Snaptime: 3 * sec, Value: 200, Usage: 50},
+			VFS:    &kstat.ZoneVFS{Snaptime: 3 * sec, Nread: 4 << 20},
+		},
+		"new": {Zonename: "new"},
+	}
+	sl := kstat.ZoneSummaries(prev, cur, 4)
+	if len(sl) != 1 || sl[0].Zonename != "web" {
+		t.Fatalf("wrong zones in summaries: %+v", sl)
+	}
+	s := sl[0]
+	if s.Interval != 2*time.Second || !near(s.CPUPct, 25) || !near(s.CapPct, 25) {
+		t.Fatalf("bad CPU summary: %+v", s)
+	}
+	if !near(s.MemPct, 50) || s.SwapPct != 0 || s.NOver != 2 || s.PagedOut != 2*4096 {
+		t.Fatalf("bad memory summary: %+v", s)
+	}
+	if !near(s.VFSRead, 2<<20) || s.ZFSRead != 0 {
+		t.Fatalf("bad I/O summary: %+v", s)
+	}
+
+	var b bytes.Buffer
+	if err := kstat.WriteZonestat(&b, sl); err != nil {
+		t.Fatalf("WriteZonestat error: %s", err)
+	}
+	exp := "ZONE               CPU%   CAP%     RSS PHYSCAP   MEM%    SWAP SWAPCAP  NOVER   VFSRD   VFSWR   ZFSRD   ZFSWR\n" +
+		"web               25.00  25.00    512M   1.00G  50.00   1.00M       -      2   2.00M       0       0       0\n"
+	if b.String() != exp {
+		t.Fatalf("WriteZonestat output wrong:\n%s\nexpected:\n%s", b.String(), exp)
+	}
+}